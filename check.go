@@ -0,0 +1,526 @@
+package main
+
+// check.go — `rocq-mcp check`: check files headlessly, through the same
+// StateManager code paths as the server, and report for CI as text, SARIF or
+// JUnit XML.
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+const checkUsage = `Usage: rocq-mcp check [flags] FILE... [-- vsrocqtop flags...]
+
+  --format text|sarif|junit  output format (default text)
+  --output FILE              write the report to FILE instead of stdout
+  --warnings-as-errors       fail on warnings too
+  --forbid-admitted          fail on Admitted proofs
+  --timeout DURATION         how long each file may take to check (default 10m)`
+
+// checkOptions are the arguments of `rocq-mcp check`.
+type checkOptions struct {
+	Files            []string
+	Format           string
+	Output           string
+	WarningsAsErrors bool
+	ForbidAdmitted   bool
+	Timeout          time.Duration
+	VsrocqArgs       []string
+}
+
+// parseCheckArgs parses the arguments after "check". Flags take their value
+// as the next argument or after '='.
+func parseCheckArgs(args []string) (checkOptions, error) {
+	o := checkOptions{Format: "text", Timeout: 10 * time.Minute}
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			o.VsrocqArgs = args[1:]
+			break
+		}
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--warnings-as-errors":
+			o.WarningsAsErrors = true
+			args = args[1:]
+			continue
+		case "--forbid-admitted":
+			o.ForbidAdmitted = true
+			args = args[1:]
+			continue
+		case "--format", "--output", "--timeout":
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return o, fmt.Errorf("unknown flag %s", arg)
+			}
+			o.Files = append(o.Files, arg)
+			args = args[1:]
+			continue
+		}
+		if !hasValue {
+			if len(args) < 2 {
+				return o, fmt.Errorf("%s requires a value", name)
+			}
+			value, args = args[1], args[1:]
+		}
+		args = args[1:]
+		switch name {
+		case "--format":
+			if value != "text" && value != "sarif" && value != "junit" {
+				return o, fmt.Errorf("--format: unknown format %q", value)
+			}
+			o.Format = value
+		case "--output":
+			o.Output = value
+		case "--timeout":
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return o, fmt.Errorf("--timeout: invalid duration %q", value)
+			}
+			o.Timeout = d
+		}
+	}
+	if len(o.Files) == 0 {
+		return o, fmt.Errorf("no files given")
+	}
+	return o, nil
+}
+
+// Finding rules.
+const (
+	ruleError    = "rocq/error"
+	ruleWarning  = "rocq/warning"
+	ruleAdmitted = "rocq/admitted"
+)
+
+// finding is one problem reported by check.
+type finding struct {
+	Rule    string
+	Range   rocq.Range
+	Message string
+	Failing bool // counts towards a non-zero exit
+}
+
+// checkedProof is a proof of a checked file, as reported by documentProofs.
+type checkedProof struct {
+	Name     string
+	Range    rocq.Range
+	Findings []finding // those within the proof
+}
+
+// checkedFile is the outcome of checking one file.
+type checkedFile struct {
+	Path     string // as reported: relative to the working directory where possible
+	Err      string // the file could not be checked
+	Time     time.Duration
+	Findings []finding // all of the file's findings
+	Proofs   []*checkedProof
+	TopLevel []finding // findings outside every proof
+}
+
+// failed reports whether the file fails the check.
+func (f *checkedFile) failed() bool {
+	if f.Err != "" {
+		return true
+	}
+	for _, fd := range f.Findings {
+		if fd.Failing {
+			return true
+		}
+	}
+	return false
+}
+
+// runCheck implements `rocq-mcp check`. It returns the process exit code: 0
+// if every file passed, 1 if any failed, 2 on error.
+func runCheck(args []string, out io.Writer) int {
+	o, err := parseCheckArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check: %v\n%s\n", err, checkUsage)
+		return 2
+	}
+	if o.Output != "" {
+		f, err := os.Create(o.Output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "check: %v\n", err)
+			return 2
+		}
+		defer f.Close()
+		out = f
+	}
+
+	sm := rocq.NewStateManager(o.VsrocqArgs)
+	defer sm.Shutdown()
+
+	var files []*checkedFile
+	for _, path := range o.Files {
+		files = append(files, checkFile(sm, path, o))
+	}
+
+	switch o.Format {
+	case "sarif":
+		err = writeSARIF(out, files)
+	case "junit":
+		err = writeJUnit(out, files)
+	default:
+		err = writeCheckText(out, files)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check: %v\n", err)
+		return 2
+	}
+	for _, f := range files {
+		if f.failed() {
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkFile opens a file, checks it to the end, and collects its findings.
+func checkFile(sm *rocq.StateManager, path string, o checkOptions) *checkedFile {
+	cf := &checkedFile{Path: displayPath(path)}
+	start := time.Now()
+	defer func() { cf.Time = time.Since(start) }()
+
+	if err := sm.OpenDoc(path); err != nil {
+		cf.Err = err.Error()
+		return cf
+	}
+	defer sm.CloseDoc(path)
+
+	// A check that did not complete fails the file, whatever it found so far.
+	diags, err := rocq.CheckToEnd(sm, path, o.Timeout)
+	if err != nil {
+		cf.Err = err.Error()
+		return cf
+	}
+
+	sm.Mu.Lock()
+	doc, err := sm.GetDoc(path)
+	content := ""
+	if err == nil {
+		content = doc.Content
+	}
+	sm.Mu.Unlock()
+	if err != nil {
+		cf.Err = err.Error()
+		return cf
+	}
+	blocks, err := rocq.FetchDocumentProofs(sm, doc)
+	if err != nil {
+		cf.Err = err.Error()
+		return cf
+	}
+	cf.collect(diags, blocks, rocq.BuildOutline(content), o)
+	return cf
+}
+
+// collect turns diagnostics and proofs into findings, and assigns each
+// finding to the proof it lies in.
+func (cf *checkedFile) collect(diags []rocq.Diagnostic, blocks []rocq.ProofBlock, outline []*rocq.OutlineEntry, o checkOptions) {
+	for _, d := range diags {
+		switch d.Severity {
+		case 1:
+			cf.Findings = append(cf.Findings, finding{Rule: ruleError, Range: d.Range, Message: d.Message, Failing: true})
+		case 2:
+			cf.Findings = append(cf.Findings, finding{Rule: ruleWarning, Range: d.Range, Message: d.Message, Failing: o.WarningsAsErrors})
+		}
+	}
+
+	admitted := map[int]bool{} // statement lines of Admitted proofs
+	var walk func([]*rocq.OutlineEntry)
+	walk = func(entries []*rocq.OutlineEntry) {
+		for _, e := range entries {
+			if e.Status == "admitted" {
+				admitted[e.Range.Start.Line] = true
+			}
+			walk(e.Children)
+		}
+	}
+	walk(outline)
+
+	for _, b := range blocks {
+		name := rocq.ProofName(b.Statement.Statement)
+		if name == "" {
+			name = fmt.Sprintf("Goal at line %d", b.Range.Start.Line+1)
+		}
+		p := &checkedProof{Name: name, Range: b.Range}
+		if o.ForbidAdmitted && admitted[b.Range.Start.Line] {
+			cf.Findings = append(cf.Findings, finding{Rule: ruleAdmitted, Range: b.Statement.Range,
+				Message: fmt.Sprintf("%s is admitted", name), Failing: true})
+		}
+		cf.Proofs = append(cf.Proofs, p)
+	}
+
+	for _, fd := range cf.Findings {
+		var in *checkedProof
+		for _, p := range cf.Proofs {
+			if !posBefore(fd.Range.Start, p.Range.Start) && !posBefore(p.Range.End, fd.Range.Start) {
+				in = p
+				break
+			}
+		}
+		if in != nil {
+			in.Findings = append(in.Findings, fd)
+		} else {
+			cf.TopLevel = append(cf.TopLevel, fd)
+		}
+	}
+}
+
+func posBefore(a, b rocq.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+}
+
+// displayPath returns path relative to the working directory if it lies
+// beneath it, with forward slashes, as SARIF and CI annotations expect.
+func displayPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, rocq.CanonicalPath(path)); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+		if rel, err := filepath.Rel(rocq.CanonicalPath(wd), rocq.CanonicalPath(path)); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// level is a finding's severity as reported: "error", "warning" or "note".
+func (fd finding) level() string {
+	switch {
+	case fd.Failing:
+		return "error"
+	case fd.Rule == ruleWarning:
+		return "warning"
+	}
+	return "note"
+}
+
+// writeCheckText prints one "file:line:col: level: message" line per finding
+// and a summary.
+func writeCheckText(w io.Writer, files []*checkedFile) error {
+	var sb strings.Builder
+	failed := 0
+	for _, f := range files {
+		if f.Err != "" {
+			fmt.Fprintf(&sb, "%s: error: %s\n", f.Path, f.Err)
+		}
+		for _, fd := range f.Findings {
+			fmt.Fprintf(&sb, "%s:%d:%d: %s: %s\n", f.Path, fd.Range.Start.Line+1, fd.Range.Start.Character+1,
+				fd.level(), strings.Join(strings.Fields(fd.Message), " "))
+		}
+		if f.failed() {
+			failed++
+		}
+	}
+	fmt.Fprintf(&sb, "%d of %d files failed\n", failed, len(files))
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// SARIF 2.1.0, the subset GitHub code scanning reads.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// writeSARIF writes the findings as a SARIF log. Files that could not be
+// checked are reported as errors without a region.
+func writeSARIF(w io.Writer, files []*checkedFile) error {
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "rocq-mcp",
+				InformationURI: "https://github.com/sanjit/rocq-mcp",
+				Rules: []sarifRule{
+					{ID: ruleError, ShortDescription: sarifMessage{Text: "Rocq error"}},
+					{ID: ruleWarning, ShortDescription: sarifMessage{Text: "Rocq warning"}},
+					{ID: ruleAdmitted, ShortDescription: sarifMessage{Text: "Admitted proof"}},
+				},
+			}},
+			Results: []sarifResult{},
+		}},
+	}
+	results := &log.Runs[0].Results
+	for _, f := range files {
+		if f.Err != "" {
+			r := sarifResult{RuleID: ruleError, Level: "error", Message: sarifMessage{Text: f.Err}, Locations: make([]sarifLocation, 1)}
+			r.Locations[0].PhysicalLocation.ArtifactLocation.URI = f.Path
+			*results = append(*results, r)
+		}
+		for _, fd := range f.Findings {
+			r := sarifResult{RuleID: fd.Rule, Level: fd.level(), Message: sarifMessage{Text: fd.Message}, Locations: make([]sarifLocation, 1)}
+			loc := &r.Locations[0].PhysicalLocation
+			loc.ArtifactLocation.URI = f.Path
+			loc.Region = &sarifRegion{
+				StartLine: fd.Range.Start.Line + 1, StartColumn: fd.Range.Start.Character + 1,
+				EndLine: fd.Range.End.Line + 1, EndColumn: fd.Range.End.Character + 1,
+			}
+			*results = append(*results, r)
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// JUnit XML, as read by CI test reporters.
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// topLevelCase names the test case for findings outside every proof.
+const topLevelCase = "(top level)"
+
+// writeJUnit writes one test suite per file and one test case per proof,
+// plus a case for the rest of the file. A case fails on its failing findings.
+func writeJUnit(w io.Writer, files []*checkedFile) error {
+	suites := junitSuites{Name: "rocq-mcp check"}
+	for _, f := range files {
+		s := junitSuite{Name: f.Path, Time: strconv.FormatFloat(f.Time.Seconds(), 'f', 3, 64)}
+		add := func(name string, findings []finding) {
+			c := junitCase{ClassName: f.Path, Name: name}
+			var lines []string
+			rule := ""
+			for _, fd := range findings {
+				if fd.Failing {
+					lines = append(lines, fmt.Sprintf("%s:%d:%d: %s", f.Path, fd.Range.Start.Line+1, fd.Range.Start.Character+1, fd.Message))
+					if rule == "" {
+						rule = fd.Rule
+					}
+				}
+			}
+			if len(lines) > 0 {
+				c.Failure = &junitProblem{Message: firstLine(findings), Type: rule, Text: strings.Join(lines, "\n")}
+				s.Failures++
+			}
+			s.Cases = append(s.Cases, c)
+			s.Tests++
+		}
+		if f.Err != "" {
+			s.Cases = append(s.Cases, junitCase{ClassName: f.Path, Name: topLevelCase,
+				Error: &junitProblem{Message: f.Err, Type: "check", Text: f.Err}})
+			s.Tests++
+			s.Errors++
+		} else {
+			add(topLevelCase, f.TopLevel)
+			for _, p := range f.Proofs {
+				add(p.Name, p.Findings)
+			}
+		}
+		suites.Tests += s.Tests
+		suites.Failures += s.Failures
+		suites.Errors += s.Errors
+		suites.Suites = append(suites.Suites, s)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// firstLine returns the first line of the first failing finding's message.
+func firstLine(findings []finding) string {
+	for _, fd := range findings {
+		if fd.Failing {
+			line, _, _ := strings.Cut(fd.Message, "\n")
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+func TestParseCheckArgs(t *testing.T) {
+	o, err := parseCheckArgs([]string{"--format=sarif", "a.v", "--forbid-admitted", "--timeout", "30s", "b.v", "--", "-Q", "theories", "Foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := checkOptions{
+		Files: []string{"a.v", "b.v"}, Format: "sarif", ForbidAdmitted: true,
+		Timeout: 30 * time.Second, VsrocqArgs: []string{"-Q", "theories", "Foo"},
+	}
+	if !reflect.DeepEqual(o, want) {
+		t.Errorf("got %+v, want %+v", o, want)
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{"--format", "xml", "a.v"},
+		{"--timeout", "soon", "a.v"},
+		{"--verbose", "a.v"},
+		{"a.v", "--output"},
+	} {
+		if _, err := parseCheckArgs(args); err == nil {
+			t.Errorf("parseCheckArgs(%q) succeeded, want an error", args)
+		}
+	}
+}
+
+func rng(line, start, end int) rocq.Range {
+	return rocq.Range{Start: rocq.Position{Line: line, Character: start}, End: rocq.Position{Line: line, Character: end}}
+}
+
+// checkedExample is a file with two proofs, one admitted and one with an
+// error, and a warning outside both.
+func checkedExample(o checkOptions) *checkedFile {
+	content := "Lemma a : True.\nProof. Admitted.\nLemma b : False.\nProof. auto. Qed.\nNotation x := 1.\n"
+	diags := []rocq.Diagnostic{
+		{Range: rng(3, 7, 12), Severity: 1, Message: "Tactic failure."},
+		{Range: rng(4, 0, 16), Severity: 2, Message: "Notation is deprecated."},
+		{Range: rng(0, 0, 15), Severity: 3, Message: "a is declared."},
+	}
+	blocks := []rocq.ProofBlock{
+		{Statement: rocq.ProofStatement{Statement: "Lemma a : True.", Range: rng(0, 0, 15)},
+			Range: rocq.Range{Start: rocq.Position{Line: 0}, End: rocq.Position{Line: 1, Character: 16}}},
+		{Statement: rocq.ProofStatement{Statement: "Lemma b : False.", Range: rng(2, 0, 16)},
+			Range: rocq.Range{Start: rocq.Position{Line: 2}, End: rocq.Position{Line: 3, Character: 17}}},
+	}
+	cf := &checkedFile{Path: "theories/Foo.v", Time: 1500 * time.Millisecond}
+	cf.collect(diags, blocks, rocq.BuildOutline(content), o)
+	return cf
+}
+
+func TestCheckedFileCollect(t *testing.T) {
+	cf := checkedExample(checkOptions{})
+	if len(cf.Findings) != 2 || !cf.failed() {
+		t.Fatalf("findings = %+v", cf.Findings)
+	}
+	if len(cf.Proofs) != 2 || cf.Proofs[0].Name != "a" || len(cf.Proofs[0].Findings) != 0 || len(cf.Proofs[1].Findings) != 1 {
+		t.Errorf("proofs = %+v", cf.Proofs)
+	}
+	if len(cf.TopLevel) != 1 || cf.TopLevel[0].Rule != ruleWarning || cf.TopLevel[0].Failing {
+		t.Errorf("top level = %+v", cf.TopLevel)
+	}
+
+	cf = checkedExample(checkOptions{WarningsAsErrors: true, ForbidAdmitted: true})
+	if len(cf.Proofs[0].Findings) != 1 || cf.Proofs[0].Findings[0].Rule != ruleAdmitted {
+		t.Errorf("proof a findings = %+v", cf.Proofs[0].Findings)
+	}
+	if !cf.TopLevel[0].Failing {
+		t.Errorf("warning not failing with --warnings-as-errors")
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	files := []*checkedFile{checkedExample(checkOptions{}), {Path: "Bar.v", Err: "file not found"}}
+	var buf bytes.Buffer
+	if err := writeSARIF(&buf, files); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("log = %+v", log)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	r := results[0]
+	if r.RuleID != ruleError || r.Level != "error" || r.Message.Text != "Tactic failure." {
+		t.Errorf("results[0] = %+v", r)
+	}
+	loc := r.Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "theories/Foo.v" || *loc.Region != (sarifRegion{StartLine: 4, StartColumn: 8, EndLine: 4, EndColumn: 13}) {
+		t.Errorf("results[0] location = %+v %+v", loc.ArtifactLocation, loc.Region)
+	}
+	if results[1].Level != "warning" {
+		t.Errorf("results[1] level = %q, want warning", results[1].Level)
+	}
+	if results[2].Locations[0].PhysicalLocation.Region != nil || results[2].Message.Text != "file not found" {
+		t.Errorf("results[2] = %+v", results[2])
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	files := []*checkedFile{checkedExample(checkOptions{ForbidAdmitted: true}), {Path: "Bar.v", Err: "file not found"}}
+	var buf bytes.Buffer
+	if err := writeJUnit(&buf, files); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Errorf("missing XML header:\n%s", buf.String())
+	}
+	var suites junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+	if suites.Tests != 4 || suites.Failures != 2 || suites.Errors != 1 || len(suites.Suites) != 2 {
+		t.Fatalf("suites = %+v", suites)
+	}
+	s := suites.Suites[0]
+	if s.Name != "theories/Foo.v" || s.Time != "1.500" {
+		t.Errorf("suite = %+v", s)
+	}
+	var names []string
+	for _, c := range s.Cases {
+		names = append(names, c.Name)
+	}
+	if want := []string{topLevelCase, "a", "b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("cases = %q, want %q", names, want)
+	}
+	if s.Cases[0].Failure != nil {
+		t.Errorf("top level failed on a warning: %+v", s.Cases[0].Failure)
+	}
+	if f := s.Cases[1].Failure; f == nil || f.Type != ruleAdmitted {
+		t.Errorf("a failure = %+v", f)
+	}
+	if f := s.Cases[2].Failure; f == nil || f.Message != "Tactic failure." || f.Text != "theories/Foo.v:4:8: Tactic failure." {
+		t.Errorf("b failure = %+v", f)
+	}
+	if e := suites.Suites[1].Cases[0].Error; e == nil || e.Message != "file not found" {
+		t.Errorf("Bar.v error = %+v", e)
+	}
+}
+
+func TestWriteCheckText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeCheckText(&buf, []*checkedFile{checkedExample(checkOptions{})}); err != nil {
+		t.Fatal(err)
+	}
+	want := "theories/Foo.v:4:8: error: Tactic failure.\n" +
+		"theories/Foo.v:5:1: warning: Notation is deprecated.\n" +
+		"1 of 1 files failed\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+// diff.go — "proof-trace diff": tracing two versions of a file, aligning
+// their sentences by proof name and tactic text, and reporting the first
+// sentence after which the proof states differ.
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+const diffUsage = `Usage: proof-trace diff <old.v> <new.v> [-- vsrocqtop flags...]
+       proof-trace diff --git-rev REV <file.v> [-- vsrocqtop flags...]
+
+  --git-rev REV   trace the file as of git revision REV as the old version
+`
+
+// diffOptions are the arguments of "proof-trace diff".
+type diffOptions struct {
+	old, new   string
+	gitRev     string // if set, old is read from git at this revision of new
+	vsrocqArgs []string
+}
+
+// parseDiffArgs parses the arguments after "diff".
+func parseDiffArgs(args []string) (diffOptions, error) {
+	var o diffOptions
+	var files []string
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			o.vsrocqArgs = args[1:]
+			break
+		}
+		name, value, hasValue := strings.Cut(arg, "=")
+		if name != "--git-rev" {
+			if strings.HasPrefix(arg, "-") {
+				return o, fmt.Errorf("unknown flag %s", arg)
+			}
+			files = append(files, arg)
+			args = args[1:]
+			continue
+		}
+		if !hasValue {
+			if len(args) < 2 {
+				return o, fmt.Errorf("%s requires a value", name)
+			}
+			value, args = args[1], args[1:]
+		}
+		args = args[1:]
+		o.gitRev = value
+	}
+	switch {
+	case o.gitRev != "" && len(files) == 1:
+		o.new = files[0]
+	case o.gitRev == "" && len(files) == 2:
+		o.old, o.new = files[0], files[1]
+	case o.gitRev != "":
+		return o, fmt.Errorf("--git-rev takes one file, got %d", len(files))
+	default:
+		return o, fmt.Errorf("two files required, got %d", len(files))
+	}
+	return o, nil
+}
+
+// runDiff implements "proof-trace diff". It reports whether the traces diverge.
+func runDiff(o diffOptions) (bool, error) {
+	oldLabel := o.old
+	if o.gitRev != "" {
+		dir, err := os.MkdirTemp("", "proof-trace-")
+		if err != nil {
+			return false, err
+		}
+		defer os.RemoveAll(dir)
+		if o.old, err = gitShow(o.gitRev, o.new, dir); err != nil {
+			return false, err
+		}
+		oldLabel = o.gitRev + ":" + o.new
+	}
+
+	sm := rocq.NewStateManager(o.vsrocqArgs)
+	defer sm.Shutdown()
+	oldSteps, err := traceFile(sm, o.old)
+	if err != nil {
+		return false, err
+	}
+	newSteps, err := traceFile(sm, o.new)
+	if err != nil {
+		return false, err
+	}
+
+	d := diffTraces(oldSteps, newSteps)
+	return d.div[1] != nil, d.write(os.Stdout, oldLabel, o.new)
+}
+
+// gitShow writes file as of rev into dir, under its own base name, and
+// returns the path written.
+func gitShow(rev, file, dir string) (string, error) {
+	cmd := exec.Command("git", "show", rev+":./"+filepath.Base(file))
+	cmd.Dir = filepath.Dir(file)
+	cmd.Stderr = os.Stderr
+	data, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git show %s: %w", rev, err)
+	}
+	path := filepath.Join(dir, filepath.Base(file))
+	return path, os.WriteFile(path, data, 0o644)
+}
+
+// traceFile opens file, traces it to the end and closes it.
+func traceFile(sm *rocq.StateManager, file string) ([]*step, error) {
+	doc, err := openDoc(sm, file)
+	if err != nil {
+		return nil, err
+	}
+	defer sm.CloseDoc(file)
+	var steps []*step
+	_, err = trace(sm, doc, func(s *step) error {
+		steps = append(steps, s)
+		return nil
+	})
+	return steps, err
+}
+
+// traceDiff is the comparison of two traces.
+type traceDiff struct {
+	aligned          int      // sentence pairs aligned
+	div              [2]*step // first aligned pair whose states differ; nil if none
+	onlyOld, onlyNew []string // proofs present in one trace only
+}
+
+// diffTraces aligns the steps of two traces, proof by proof, on their
+// sentence text, and finds the first pair (in new-file order) after which
+// the goals or errors differ.
+func diffTraces(oldSteps, newSteps []*step) traceDiff {
+	oldGroups, oldOrder := groupByProof(oldSteps)
+	newGroups, newOrder := groupByProof(newSteps)
+
+	var d traceDiff
+	match := map[*step]*step{}
+	for _, name := range newOrder {
+		olds, ok := oldGroups[name]
+		if !ok {
+			d.onlyNew = append(d.onlyNew, name)
+			continue
+		}
+		news := newGroups[name]
+		for _, p := range lcs(len(olds), len(news), func(i, j int) bool {
+			return collapseSpace(olds[i].Sentence) == collapseSpace(news[j].Sentence)
+		}) {
+			match[news[p[1]]] = olds[p[0]]
+		}
+	}
+	for _, name := range oldOrder {
+		if _, ok := newGroups[name]; !ok {
+			d.onlyOld = append(d.onlyOld, name)
+		}
+	}
+
+	for _, s := range newSteps {
+		o, ok := match[s]
+		if !ok {
+			continue
+		}
+		d.aligned++
+		if d.div[1] == nil && !sameState(o, s) {
+			d.div = [2]*step{o, s}
+		}
+	}
+	return d
+}
+
+// groupByProof splits steps by the proof they belong to, keeping sentences
+// outside proofs together. It also returns the group names in order.
+func groupByProof(steps []*step) (map[string][]*step, []string) {
+	groups := map[string][]*step{}
+	var order []string
+	for _, s := range steps {
+		name := proofName(s.proof)
+		if _, ok := groups[name]; !ok {
+			order = append(order, name)
+		}
+		groups[name] = append(groups[name], s)
+	}
+	return groups, order
+}
+
+// goalLines renders a goal for comparison, without its ID, which differs between runs.
+func goalLines(g goal) []string {
+	return append(slices.Clone(g.Hypotheses), "────────────────────", g.Conclusion)
+}
+
+// errorLines returns the messages of the errors in a step's sentence.
+func errorLines(s *step) []string {
+	var errs []string
+	for _, d := range s.sentenceDiagnostics() {
+		if d.Severity == 1 {
+			errs = append(errs, collapseSpace(d.Message))
+		}
+	}
+	return errs
+}
+
+// sameState reports whether two steps left the same goals and errors.
+func sameState(a, b *step) bool {
+	if len(a.Goals) != len(b.Goals) || !slices.Equal(errorLines(a), errorLines(b)) {
+		return false
+	}
+	for i := range a.Goals {
+		if !slices.Equal(goalLines(a.Goals[i]), goalLines(b.Goals[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// write reports the comparison.
+func (d traceDiff) write(w io.Writer, oldLabel, newLabel string) error {
+	var sb strings.Builder
+	if o, n := d.div[0], d.div[1]; o == nil {
+		fmt.Fprintf(&sb, "No divergence: %d aligned sentences leave the same goals.\n", d.aligned)
+	} else {
+		fmt.Fprintf(&sb, "First divergence in %s, after:\n", proofName(n.proof))
+		fmt.Fprintf(&sb, "  old %s:%d  > %s\n", oldLabel, o.Range.Start.Line+1, oneLine(o.Sentence, 80))
+		fmt.Fprintf(&sb, "  new %s:%d  > %s\n", newLabel, n.Range.Start.Line+1, oneLine(n.Sentence, 80))
+		for i := range max(len(o.Goals), len(n.Goals)) {
+			var a, b []string
+			label := fmt.Sprintf("Goal %d", i+1)
+			switch {
+			case i >= len(o.Goals):
+				b, label = goalLines(n.Goals[i]), label+" (new only)"
+			case i >= len(n.Goals):
+				a, label = goalLines(o.Goals[i]), label+" (old only)"
+			default:
+				a, b = goalLines(o.Goals[i]), goalLines(n.Goals[i])
+				if slices.Equal(a, b) {
+					continue
+				}
+			}
+			fmt.Fprintf(&sb, "\n%s:\n", label)
+			writeLineDiff(&sb, a, b)
+		}
+		if a, b := errorLines(o), errorLines(n); !slices.Equal(a, b) {
+			sb.WriteString("\nErrors:\n")
+			writeLineDiff(&sb, a, b)
+		}
+	}
+	if len(d.onlyOld) > 0 {
+		fmt.Fprintf(&sb, "\nOnly in old: %s\n", strings.Join(d.onlyOld, ", "))
+	}
+	if len(d.onlyNew) > 0 {
+		fmt.Fprintf(&sb, "\nOnly in new: %s\n", strings.Join(d.onlyNew, ", "))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeLineDiff writes a unified-style diff of two line lists: common lines
+// indented, removed ones with "-", added ones with "+".
+func writeLineDiff(sb *strings.Builder, a, b []string) {
+	i, j := 0, 0
+	for _, p := range append(lcs(len(a), len(b), func(i, j int) bool { return a[i] == b[j] }), [2]int{len(a), len(b)}) {
+		for ; i < p[0]; i++ {
+			fmt.Fprintf(sb, "  - %s\n", a[i])
+		}
+		for ; j < p[1]; j++ {
+			fmt.Fprintf(sb, "  + %s\n", b[j])
+		}
+		if i < len(a) {
+			fmt.Fprintf(sb, "    %s\n", a[i])
+			i++
+			j++
+		}
+	}
+}
+
+// lcs returns the index pairs of a longest common subsequence of two
+// sequences of lengths n and m, compared with eq.
+func lcs(n, m int, eq func(i, j int) bool) [][2]int {
+	// l[i][j] is the LCS length of the suffixes starting at i and j.
+	l := make([][]int, n+1)
+	for i := range l {
+		l[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if eq(i, j) {
+				l[i][j] = l[i+1][j+1] + 1
+			} else {
+				l[i][j] = max(l[i+1][j], l[i][j+1])
+			}
+		}
+	}
+	var pairs [][2]int
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case eq(i, j):
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case l[i+1][j] >= l[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
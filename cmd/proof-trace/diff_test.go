@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+func TestParseDiffArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want diffOptions
+	}{
+		{[]string{"a.v", "b.v"}, diffOptions{old: "a.v", new: "b.v"}},
+		{[]string{"--git-rev", "HEAD~1", "b.v", "--", "-Q", ".", "X"},
+			diffOptions{new: "b.v", gitRev: "HEAD~1", vsrocqArgs: []string{"-Q", ".", "X"}}},
+		{[]string{"b.v", "--git-rev=main"}, diffOptions{new: "b.v", gitRev: "main"}},
+	}
+	for _, tt := range tests {
+		got, err := parseDiffArgs(tt.args)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseDiffArgs(%q) = %+v, %v; want %+v", tt.args, got, err, tt.want)
+		}
+	}
+	for _, args := range [][]string{nil, {"a.v"}, {"a.v", "b.v", "c.v"}, {"--git-rev", "HEAD", "a.v", "b.v"}, {"--git-rev"}, {"-x", "a.v", "b.v"}} {
+		if _, err := parseDiffArgs(args); err == nil {
+			t.Errorf("parseDiffArgs(%q): expected error", args)
+		}
+	}
+}
+
+// traceOf builds a trace of content in which each sentence leaves the given
+// goal conclusions (one per goal, no hypotheses).
+func traceOf(content string, goals map[string][]string) []*step {
+	proofs := findProofs(content)
+	var steps []*step
+	for i, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		pv := &rocq.ProofView{}
+		for _, c := range goals[strings.TrimSpace(line)] {
+			pv.Goals = append(pv.Goals, rocq.Goal{ID: "x", Hypotheses: []string{"n : nat"}, Conclusion: c})
+		}
+		r := rocq.Range{Start: rocq.Position{Line: i}, End: rocq.Position{Line: i, Character: len(line)}}
+		s := newStep(len(steps)+1, strings.TrimSpace(line), r, pv, nil)
+		s.proof = proofAt(proofs, r.Start)
+		steps = append(steps, s)
+	}
+	return steps
+}
+
+func TestDiffTraces(t *testing.T) {
+	old := traceOf("Lemma gone : True.\nQed.\nLemma foo : P.\nintros.\nsimpl.\nauto.\nQed.\n", map[string][]string{
+		"Lemma foo : P.": {"P"}, "intros.": {"Q n"}, "simpl.": {"R n"},
+	})
+	// A new lemma shifts foo down, an added tactic is skipped in the
+	// alignment, and simpl now leaves two goals.
+	cur := traceOf("Lemma added : True.\nQed.\nLemma foo : P.\nintros.\nidtac.\nsimpl.\nauto.\nQed.\n", map[string][]string{
+		"Lemma foo : P.": {"P"}, "intros.": {"Q n"}, "idtac.": {"Q n"}, "simpl.": {"R' n", "S n"},
+	})
+
+	d := diffTraces(old, cur)
+	if d.div[1] == nil || d.div[1].Sentence != "simpl." || d.div[0].Range.Start.Line != 4 {
+		t.Fatalf("divergence at %+v", d.div)
+	}
+	var sb strings.Builder
+	if err := d.write(&sb, "old.v", "new.v"); err != nil {
+		t.Fatal(err)
+	}
+	want := `First divergence in foo, after:
+  old old.v:5  > simpl.
+  new new.v:6  > simpl.
+
+Goal 1:
+    n : nat
+    ────────────────────
+  - R n
+  + R' n
+
+Goal 2 (new only):
+  + n : nat
+  + ────────────────────
+  + S n
+
+Only in old: gone
+
+Only in new: added
+`
+	if sb.String() != want {
+		t.Errorf("report:\n%s\nwant:\n%s", sb.String(), want)
+	}
+
+	if d := diffTraces(old, old); d.div[1] != nil || d.aligned != len(old) {
+		t.Errorf("self-diff: %+v", d)
+	}
+}
+
+func TestGitShow(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	path := filepath.Join(repo, "sub", "a.v")
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	os.WriteFile(path, []byte("Lemma v1 : True.\n"), 0o644)
+	git("add", ".")
+	git("commit", "-qm", "v1")
+	os.WriteFile(path, []byte("Lemma v2 : True.\n"), 0o644)
+
+	got, err := gitShow("HEAD", path, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(got); string(data) != "Lemma v1 : True.\n" || filepath.Base(got) != "a.v" {
+		t.Errorf("gitShow wrote %s: %q", got, data)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+// html.go — rendering a trace as a self-contained HTML page: the source as
+// written, each sentence followed by a collapsible view of the proof state
+// after it.
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+// htmlWriter collects the steps of a trace and writes the page once it is done.
+type htmlWriter struct {
+	w       io.Writer
+	title   string
+	content string // the traced source
+	steps   []*step
+}
+
+func (h *htmlWriter) step(s *step) error {
+	h.steps = append(h.steps, s)
+	return nil
+}
+
+// done writes the page. The source between sentences (comments, blank lines)
+// is copied as is; each sentence is a toggle for the state after it, and the
+// ranges of errors within it are highlighted. Without scripts: the toggles
+// are checkboxes, as in Alectryon.
+func (h *htmlWriter) done(int) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, htmlHeader, html.EscapeString(h.title))
+
+	pos := 0
+	for _, s := range h.steps {
+		start := max(rocq.PositionToOffset(h.content, s.Range.Start), pos)
+		end := min(rocq.PositionToOffset(h.content, s.Range.End), len(h.content))
+		if s.Sentence == "" || end <= start {
+			continue
+		}
+		sb.WriteString(html.EscapeString(h.content[pos:start]))
+
+		class := "sentence"
+		if len(errorLines(s)) > 0 {
+			class += " failed"
+		}
+		fmt.Fprintf(&sb, `<input type="checkbox" class="toggle" id="s%d">`, s.Index)
+		fmt.Fprintf(&sb, `<label for="s%d" class="%s">`, s.Index, class)
+		writeHighlighted(&sb, h.content, start, end, s.sentenceDiagnostics())
+		sb.WriteString(`</label>`)
+		writeState(&sb, s)
+		pos = end
+	}
+	sb.WriteString(html.EscapeString(h.content[pos:]))
+	sb.WriteString(htmlFooter)
+
+	_, err := io.WriteString(h.w, sb.String())
+	return err
+}
+
+// writeHighlighted writes content[start:end], marking the parts covered by
+// error diagnostics.
+func writeHighlighted(sb *strings.Builder, content string, start, end int, diags []rocq.Diagnostic) {
+	marked := make([]string, end-start) // error message per byte, if any
+	for _, d := range diags {
+		if d.Severity != 1 {
+			continue
+		}
+		from := max(rocq.PositionToOffset(content, d.Range.Start), start)
+		to := min(rocq.PositionToOffset(content, d.Range.End), end)
+		for i := from; i < to; i++ {
+			if marked[i-start] == "" {
+				marked[i-start] = collapseSpace(d.Message)
+			}
+		}
+	}
+	for i := start; i < end; {
+		j := i + 1
+		for j < end && marked[j-start] == marked[i-start] {
+			j++
+		}
+		text := html.EscapeString(content[i:j])
+		if msg := marked[i-start]; msg != "" {
+			fmt.Fprintf(sb, `<span class="error" title="%s">%s</span>`, html.EscapeString(msg), text)
+		} else {
+			sb.WriteString(text)
+		}
+		i = j
+	}
+}
+
+// writeState writes the goals and messages after a step, and the diagnostics
+// of its sentence.
+func writeState(sb *strings.Builder, s *step) {
+	sb.WriteString(`<div class="state">`)
+	if s.pv != nil {
+		if len(s.Goals) == 0 {
+			sb.WriteString(`<div class="none">No goals</div>`)
+		}
+		for i, g := range s.Goals {
+			fmt.Fprintf(sb, `<div class="goal"><div class="goal-name">Goal %d`, i+1)
+			if g.ID != "" {
+				fmt.Fprintf(sb, ` (%s)`, html.EscapeString(g.ID))
+			}
+			sb.WriteString(`</div>`)
+			for _, hyp := range g.Hypotheses {
+				fmt.Fprintf(sb, `<div class="hyp">%s</div>`, html.EscapeString(hyp))
+			}
+			fmt.Fprintf(sb, `<div class="conclusion">%s</div></div>`, html.EscapeString(g.Conclusion))
+		}
+		if bg := rocq.FormatBackgroundCounts(s.pv); bg != "" {
+			fmt.Fprintf(sb, `<div class="none">(%s)</div>`, html.EscapeString(bg))
+		}
+	}
+	for _, m := range s.Messages {
+		fmt.Fprintf(sb, `<div class="message">%s</div>`, html.EscapeString(m))
+	}
+	for _, d := range s.sentenceDiagnostics() {
+		class := "info"
+		switch d.Severity {
+		case 1:
+			class = "error"
+		case 2:
+			class = "warning"
+		}
+		fmt.Fprintf(sb, `<div class="diagnostic %s">%s</div>`, class, html.EscapeString(d.Message))
+	}
+	sb.WriteString(`</div>`)
+}
+
+const htmlHeader = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { margin: 2em auto; max-width: 60em; padding: 0 1em; font-family: sans-serif; }
+.source { font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 14px; line-height: 1.4; white-space: pre-wrap; }
+.toggle { display: none; }
+.sentence { cursor: pointer; border-radius: 3px; }
+.sentence:hover { background: #eef3fb; }
+.toggle:checked + .sentence { background: #dde8f8; }
+.failed { background: #fdecec; }
+.error { text-decoration: underline wavy #d33; background: #fbd5d5; }
+.state { display: none; white-space: normal; margin: .3em 0 .6em 1.5em; padding: .4em .8em; border-left: 3px solid #9ab; background: #f7f9fb; }
+.toggle:checked + .sentence + .state, #show-all:checked ~ .source .state { display: block; }
+.state > div { white-space: pre-wrap; }
+.goal { margin: .2em 0 .5em; }
+.goal-name { color: #678; font-size: 85%%; }
+.conclusion { border-top: 1px solid #789; margin-top: .2em; padding-top: .2em; }
+.none { color: #678; font-style: italic; }
+.message { margin-top: .3em; }
+.diagnostic { margin-top: .3em; padding-left: .4em; border-left: 3px solid #999; }
+.diagnostic.error { border-color: #d33; text-decoration: none; background: none; }
+.diagnostic.warning { border-color: #c90; }
+</style>
+</head>
+<body>
+<h1>%[1]s</h1>
+<input type="checkbox" id="show-all"> <label for="show-all">Show all proof states</label>
+<div class="source">`
+
+const htmlFooter = `</div>
+</body>
+</html>
+`
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+func TestHTMLPage(t *testing.T) {
+	content := "(* A <lemma>. *)\nLemma foo : True.\nProof.\n  exact 0.\nQed.\n"
+	at := func(line, start, end int) rocq.Range {
+		return rocq.Range{Start: rocq.Position{Line: line, Character: start}, End: rocq.Position{Line: line, Character: end}}
+	}
+	pv := &rocq.ProofView{Goals: []rocq.Goal{{ID: "1", Conclusion: "True"}}}
+	diag := rocq.Diagnostic{Range: at(3, 8, 9), Severity: 1, Message: "The term \"0\" has type \"nat\"."}
+
+	var buf bytes.Buffer
+	h := &htmlWriter{w: &buf, title: "a.v", content: content}
+	h.step(newStep(1, "Lemma foo : True.", at(1, 0, 17), pv, nil))
+	h.step(newStep(2, "Proof.", at(2, 0, 6), pv, nil))
+	h.step(newStep(3, "exact 0.", at(3, 2, 10), pv, []rocq.Diagnostic{diag}))
+	// vsrocq publishes the whole document's diagnostics: the error is not Qed's.
+	h.step(newStep(4, "Qed.", at(4, 0, 4), nil, []rocq.Diagnostic{diag}))
+	if err := h.done(4); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"<title>a.v</title>",
+		"(* A &lt;lemma&gt;. *)\n" + `<input type="checkbox" class="toggle" id="s1"><label for="s1" class="sentence">Lemma foo : True.</label>`,
+		`<div class="goal"><div class="goal-name">Goal 1 (1)</div><div class="conclusion">True</div></div>`,
+		"\n  " + `<input type="checkbox" class="toggle" id="s3"><label for="s3" class="sentence failed">exact <span class="error" title="The term &#34;0&#34; has type &#34;nat&#34;.">0</span>.</label>`,
+		`<div class="diagnostic error">The term &#34;0&#34; has type &#34;nat&#34;.</div>`,
+		`<label for="s4" class="sentence">Qed.</label><div class="state"></div>`,
+		"</div>\n</div>\n</body>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("page lacks %q:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, `class="diagnostic error"`); n != 1 {
+		t.Errorf("error shown in %d state panels, want 1", n)
+	}
+}
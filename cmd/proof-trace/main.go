@@ -1,166 +1,204 @@
 package main
 
 // proof-trace steps through every sentence in a .v file and prints the full
-// proof state returned by vsrocqtop at each step. For debugging.
+// proof state returned by vsrocqtop at each step. For debugging, with
+// --format jsonl for building datasets and diffing traces, and with --profile
+// for finding slow tactics, and with --html for reading a proof with its
+// goals in a browser. "proof-trace diff" compares the traces of two
+// versions of a file.
 
 import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/sanjit/rocq-mcp/internal/rocq"
 )
 
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: proof-trace <file.v> [-- vsrocqtop flags...]\n")
-		os.Exit(1)
-	}
+const usage = `Usage: proof-trace [flags] <file.v> [-- vsrocqtop flags...]
+       proof-trace --project PATH [--json FILE] [-- vsrocqtop flags...]
+       proof-trace diff [--git-rev REV] [old.v] <new.v> [-- vsrocqtop flags...]
+
+  --format text   human-readable proof state after each sentence (default)
+  --format jsonl  one JSON record per sentence
+  --profile       time each sentence; print the slowest sentences and proofs
+  --top N         rows in each --profile table (default 20)
+  --folded FILE   with --profile, also write folded stacks for flame graphs
+  --html FILE     write the file as an HTML page with the proof state after
+                  each sentence, instead of the trace
+  --project PATH  trace every file of a _RocqProject (or of the one in a
+                  directory) in dependency order and report each proof's status
+  --json FILE     with --project, also write the report as JSON ("-": stdout only)
+`
+
+// options are proof-trace's command-line arguments.
+type options struct {
+	file       string
+	format     string   // "text" or "jsonl"
+	profile    bool     // report timings instead of the trace
+	top        int      // rows per profile table
+	folded     string   // file for folded stacks, with profile
+	html       string   // file for the HTML page
+	project    string   // project file or directory; replaces file
+	json       string   // file for the JSON project report, with project
+	vsrocqArgs []string // after "--"
+}
 
-	file := os.Args[1]
-	var vsrocqArgs []string
-	for i, arg := range os.Args[2:] {
+// parseArgs parses the arguments after the program name. Flags take their
+// value as the next argument or after '='.
+func parseArgs(args []string) (options, error) {
+	o := options{format: "text", top: 20}
+	for len(args) > 0 {
+		arg := args[0]
 		if arg == "--" {
-			vsrocqArgs = os.Args[i+3:]
+			o.vsrocqArgs = args[1:]
 			break
 		}
-	}
-
-	sm := rocq.NewStateManager(vsrocqArgs)
-	defer sm.Shutdown()
-
-	if err := sm.OpenDoc(file); err != nil {
-		log.Fatalf("open: %v", err)
-	}
-	defer sm.CloseDoc(file)
-
-	sm.Mu.Lock()
-	doc, err := sm.GetDoc(file)
-	sm.Mu.Unlock()
-	if err != nil {
-		log.Fatalf("getDoc: %v", err)
-	}
-
-	content := doc.Content
-	prevOffset := 0
-	step := 0
-
-	for {
-		rocq.DrainChannels(doc)
-
-		params := map[string]any{
-			"textDocument": map[string]any{"uri": doc.URI, "version": doc.Version},
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--profile":
+			o.profile = true
+			args = args[1:]
+			continue
+		case "--format", "--top", "--folded", "--html", "--project", "--json":
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return o, fmt.Errorf("unknown flag %s", arg)
+			}
+			if o.file != "" {
+				return o, fmt.Errorf("unexpected argument %q", arg)
+			}
+			o.file = arg
+			args = args[1:]
+			continue
 		}
-		if err := sm.Client.Notify("prover/stepForward", params); err != nil {
-			log.Fatalf("stepForward: %v", err)
+		if !hasValue {
+			if len(args) < 2 {
+				return o, fmt.Errorf("%s requires a value", name)
+			}
+			value, args = args[1], args[1:]
 		}
-
-		// Wait for moveCursor, proofView, and diagnostics.
-		var cursorPos *rocq.Position
-		var pv *rocq.ProofView
-		var diags []rocq.Diagnostic
-
-		timer := time.NewTimer(5 * time.Second)
-		gotCursor := false
-		gotProofView := false
-		gotDiags := false
-
-		for !gotCursor || !gotProofView || !gotDiags {
-			select {
-			case pos := <-doc.CursorCh:
-				cursorPos = &pos
-				gotCursor = true
-			case p := <-doc.ProofViewCh:
-				pv = p
-				gotProofView = true
-			case d := <-doc.DiagnosticCh:
-				diags = d
-				gotDiags = true
-			case <-timer.C:
-				goto done
+		args = args[1:]
+		switch name {
+		case "--format":
+			if value != "text" && value != "jsonl" {
+				return o, fmt.Errorf("--format: unknown format %q", value)
 			}
-			// After first notification, shorten timeout for the rest.
-			if !timer.Stop() {
-				select {
-				case <-timer.C:
-				default:
-				}
+			o.format = value
+		case "--top":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return o, fmt.Errorf("--top: invalid count %q", value)
 			}
-			timer.Reset(500 * time.Millisecond)
+			o.top = n
+		case "--folded":
+			o.folded = value
+		case "--html":
+			o.html = value
+		case "--project":
+			o.project = value
+		case "--json":
+			o.json = value
 		}
-	done:
-		timer.Stop()
+	}
+	switch {
+	case o.project != "" && (o.file != "" || o.profile || o.format != "text" || o.html != ""):
+		return o, fmt.Errorf("--project takes no file and cannot be combined with --profile, --format or --html")
+	case o.project == "" && o.json != "":
+		return o, fmt.Errorf("--json requires --project")
+	case o.project == "" && o.file == "":
+		return o, fmt.Errorf("no file given")
+	}
+	if o.folded != "" && !o.profile {
+		return o, fmt.Errorf("--folded requires --profile")
+	}
+	if o.profile && o.format != "text" {
+		return o, fmt.Errorf("--profile cannot be combined with --format jsonl")
+	}
+	if o.html != "" && (o.profile || o.format != "text") {
+		return o, fmt.Errorf("--html cannot be combined with --profile or --format")
+	}
+	return o, nil
+}
 
-		// No cursor movement means vsrocqtop didn't step — we're at the end.
-		if cursorPos == nil {
-			break
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		o, err := parseDiffArgs(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "proof-trace diff: %v\n%s", err, diffUsage)
+			os.Exit(2)
 		}
-
-		step++
-
-		// Extract sentence text from file content.
-		newOffset := positionToOffset(content, *cursorPos)
-		sentence := ""
-		if newOffset > prevOffset {
-			sentence = strings.TrimSpace(content[prevOffset:newOffset])
+		// Exit status as for diff(1): 1 if the traces diverge, 2 on trouble.
+		diverged, err := runDiff(o)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "proof-trace diff: %v\n", err)
+			os.Exit(2)
 		}
-		prevOffset = newOffset
-
-		// Print step header.
-		fmt.Printf("=== Step %d ===\n", step)
-		if sentence != "" {
-			fmt.Printf("> %s\n", sentence)
+		if diverged {
+			os.Exit(1)
 		}
-		fmt.Println()
+		return
+	}
 
-		// Print proof state.
-		if pv != nil {
-			var sb strings.Builder
-			if len(pv.Goals) > 0 {
-				rocq.WriteGoals(&sb, pv.Goals)
-			} else {
-				sb.WriteString("Focused Goals (0)\n")
-			}
-			bg := rocq.FormatBackgroundCounts(pv)
-			if bg != "" {
-				fmt.Fprintf(&sb, "(%s)\n", bg)
-			}
-			fmt.Print(sb.String())
-		}
+	o, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "proof-trace: %v\n%s", err, usage)
+		os.Exit(1)
+	}
 
-		// Print messages.
-		if pv != nil && len(pv.Messages) > 0 {
-			fmt.Printf("\nMessages (%d):\n", len(pv.Messages))
-			for _, m := range pv.Messages {
-				fmt.Printf("  %s\n", m)
-			}
+	if o.project != "" {
+		failed, err := runProject(o.project, o.vsrocqArgs, os.Stdout, o.json)
+		if err != nil {
+			log.Fatal(err)
 		}
-
-		// Print diagnostics.
-		if len(diags) > 0 {
-			var sb strings.Builder
-			rocq.FormatDiagnostics(&sb, diags)
-			fmt.Print(sb.String())
+		if failed {
+			os.Exit(1)
 		}
-
-		fmt.Println()
+		return
 	}
 
-	fmt.Printf("--- Done: %d steps ---\n", step)
-}
+	sm := rocq.NewStateManager(o.vsrocqArgs)
+	defer sm.Shutdown()
 
-// positionToOffset converts an LSP Position (line, character) to a byte offset in content.
-func positionToOffset(content string, pos rocq.Position) int {
-	line := 0
-	for i, ch := range content {
-		if line == pos.Line {
-			return i + pos.Character
+	doc, err := openDoc(sm, o.file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer sm.CloseDoc(o.file)
+
+	var w traceWriter = &textWriter{w: os.Stdout}
+	switch {
+	case o.profile:
+		pw := &profileWriter{w: os.Stdout, file: o.file, top: o.top}
+		if o.folded != "" {
+			f, err := os.Create(o.folded)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			pw.folded = f
 		}
-		if ch == '\n' {
-			line++
+		w = pw
+	case o.html != "":
+		f, err := os.Create(o.html)
+		if err != nil {
+			log.Fatal(err)
 		}
+		defer f.Close()
+		sm.Mu.Lock()
+		content := doc.Content
+		sm.Mu.Unlock()
+		w = &htmlWriter{w: f, title: o.file, content: content}
+	case o.format == "jsonl":
+		w = newJSONLWriter(os.Stdout)
+	}
+	steps, err := trace(sm, doc, w.step)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := w.done(steps); err != nil {
+		log.Fatal(err)
 	}
-	return len(content)
 }
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want options
+	}{
+		{[]string{"a.v"}, options{file: "a.v", format: "text", top: 20}},
+		{[]string{"--format", "jsonl", "a.v"}, options{file: "a.v", format: "jsonl", top: 20}},
+		{[]string{"a.v", "--format=jsonl", "--", "-Q", ".", "X"},
+			options{file: "a.v", format: "jsonl", top: 20, vsrocqArgs: []string{"-Q", ".", "X"}}},
+		{[]string{"--profile", "--top=5", "--folded", "out.folded", "a.v"},
+			options{file: "a.v", format: "text", profile: true, top: 5, folded: "out.folded"}},
+		{[]string{"--project", ".", "--json=report.json", "--", "-w", "none"},
+			options{format: "text", top: 20, project: ".", json: "report.json", vsrocqArgs: []string{"-w", "none"}}},
+		{[]string{"--html=out.html", "a.v"}, options{file: "a.v", format: "text", top: 20, html: "out.html"}},
+	}
+	for _, tt := range tests {
+		got, err := parseArgs(tt.args)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseArgs(%q) = %+v, %v; want %+v", tt.args, got, err, tt.want)
+		}
+	}
+
+	for _, args := range [][]string{nil, {"--format"}, {"--format=xml", "a.v"}, {"--bogus", "a.v"}, {"a.v", "b.v"}, {"--top=0", "--profile", "a.v"},
+		{"--folded=x", "a.v"}, {"--profile", "--format=jsonl", "a.v"},
+		{"--project", ".", "a.v"}, {"--project", ".", "--profile"}, {"--json", "r.json", "a.v"},
+		{"--html", "out.html", "--profile", "a.v"}, {"--project", ".", "--html", "out.html"}} {
+		if _, err := parseArgs(args); err == nil {
+			t.Errorf("parseArgs(%q): expected error", args)
+		}
+	}
+}
+
+func TestJSONLRecord(t *testing.T) {
+	pv := &rocq.ProofView{
+		ShelvedCount: 1,
+		Goals:        []rocq.Goal{{ID: "2", Hypotheses: []string{"n : nat"}, Conclusion: "0 + n = n"}},
+	}
+	r := rocq.Range{Start: rocq.Position{Line: 4, Character: 2}, End: rocq.Position{Line: 4, Character: 11}}
+	var buf bytes.Buffer
+	w := newJSONLWriter(&buf)
+	if err := w.step(newStep(3, "intros n.", r, pv, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.step(newStep(4, "Qed.", r, nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines: %s", len(lines), buf.String())
+	}
+	want := `{"step":3,"sentence":"intros n.","range":{"start":{"line":4,"character":2},"end":{"line":4,"character":11}},` +
+		`"goals":[{"id":"2","hypotheses":["n : nat"],"conclusion":"0 + n = n"}],` +
+		`"background":{"unfocused":0,"shelved":1,"given_up":0},"messages":[],"diagnostics":[]}`
+	if string(lines[0]) != want {
+		t.Errorf("record:\n got %s\nwant %s", lines[0], want)
+	}
+	var rec map[string]any
+	if err := json.Unmarshal(lines[1], &rec); err != nil {
+		t.Fatal(err)
+	}
+	if goals, ok := rec["goals"].([]any); !ok || len(goals) != 0 {
+		t.Errorf("goals without a proof view: %v", rec["goals"])
+	}
+}
+
+func TestProfile(t *testing.T) {
+	content := "Definition n := 1.\nLemma foo : True.\nProof.\n  idtac; exact I.\nQed.\n"
+	proofs := findProofs(content)
+	at := func(line int) rocq.Range {
+		return rocq.Range{Start: rocq.Position{Line: line}, End: rocq.Position{Line: line, Character: 1}}
+	}
+	var table, folded bytes.Buffer
+	p := &profileWriter{w: &table, file: "a.v", top: 2, folded: &folded}
+	for i, s := range []struct {
+		line     int
+		sentence string
+		ms       int
+	}{{0, "Definition n := 1.", 5}, {1, "Lemma foo : True.", 1}, {2, "Proof.", 1}, {3, "idtac; exact I.", 40}, {4, "Qed.", 3}} {
+		st := newStep(i+1, s.sentence, at(s.line), nil, nil)
+		st.Duration = time.Duration(s.ms) * time.Millisecond
+		st.proof = proofAt(proofs, st.Range.Start)
+		p.step(st)
+	}
+	if err := p.done(5); err != nil {
+		t.Fatal(err)
+	}
+
+	out := table.String()
+	for _, want := range []string{
+		"Slowest sentences (2 of 5, 0.050s total):",
+		"0.040s  a.v:4                     foo                       idtac; exact I.",
+		"0.005s  a.v:1                     (top level)               Definition n := 1.",
+		"Time per proof (1 of 1):",
+		"0.045s      4  a.v:2                     foo",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("table lacks %q:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(folded.String(), "foo;4: idtac； exact I. 40000\n") ||
+		!strings.Contains(folded.String(), "(top level);1: Definition n := 1. 5000\n") {
+		t.Errorf("folded stacks:\n%s", folded.String())
+	}
+}
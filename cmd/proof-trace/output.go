@@ -0,0 +1,80 @@
+package main
+
+// output.go — rendering a trace as human-readable text or as JSON Lines.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+// traceWriter renders a trace step by step as it is produced.
+type traceWriter interface {
+	step(s *step) error
+	done(steps int) error
+}
+
+// textWriter prints each step's sentence, goals, messages and diagnostics.
+type textWriter struct {
+	w io.Writer
+}
+
+func (t *textWriter) step(s *step) error {
+	var sb strings.Builder
+
+	// Step header.
+	fmt.Fprintf(&sb, "=== Step %d ===\n", s.Index)
+	if s.Sentence != "" {
+		fmt.Fprintf(&sb, "> %s\n", s.Sentence)
+	}
+	sb.WriteString("\n")
+
+	// Proof state.
+	if pv := s.pv; pv != nil {
+		if len(pv.Goals) > 0 {
+			rocq.WriteGoals(&sb, pv.Goals)
+		} else {
+			sb.WriteString("Focused Goals (0)\n")
+		}
+		if bg := rocq.FormatBackgroundCounts(pv); bg != "" {
+			fmt.Fprintf(&sb, "(%s)\n", bg)
+		}
+		if len(pv.Messages) > 0 {
+			fmt.Fprintf(&sb, "\nMessages (%d):\n", len(pv.Messages))
+			for _, m := range pv.Messages {
+				fmt.Fprintf(&sb, "  %s\n", m)
+			}
+		}
+	}
+
+	if len(s.Diagnostics) > 0 {
+		rocq.FormatDiagnostics(&sb, s.Diagnostics)
+	}
+	sb.WriteString("\n")
+
+	_, err := io.WriteString(t.w, sb.String())
+	return err
+}
+
+func (t *textWriter) done(steps int) error {
+	_, err := fmt.Fprintf(t.w, "--- Done: %d steps ---\n", steps)
+	return err
+}
+
+// jsonlWriter writes one JSON object per step, one per line.
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &jsonlWriter{enc: enc}
+}
+
+func (j *jsonlWriter) step(s *step) error { return j.enc.Encode(s) }
+
+func (j *jsonlWriter) done(int) error { return nil }
@@ -0,0 +1,127 @@
+package main
+
+// profile.go — per-sentence timing: a table of the slowest sentences and of
+// the time spent in each proof, and folded stacks for flame graph tools.
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// topLevel names the frame of sentences outside any proof in folded stacks.
+const topLevel = "(top level)"
+
+// profileWriter collects step timings and reports them once the trace is done.
+type profileWriter struct {
+	w      io.Writer
+	file   string
+	top    int       // rows in each table
+	folded io.Writer // if non-nil, folded stacks are written here
+	steps  []*step
+}
+
+func (p *profileWriter) step(s *step) error {
+	p.steps = append(p.steps, s)
+	return nil
+}
+
+func (p *profileWriter) done(int) error {
+	var sb strings.Builder
+	var total time.Duration
+	for _, s := range p.steps {
+		total += s.Duration
+	}
+
+	slowest := slices.Clone(p.steps)
+	slices.SortStableFunc(slowest, func(a, b *step) int { return cmp.Compare(b.Duration, a.Duration) })
+	fmt.Fprintf(&sb, "Slowest sentences (%d of %d, %s total):\n", min(p.top, len(slowest)), len(slowest), fmtDuration(total))
+	fmt.Fprintf(&sb, "%10s  %-24s  %-24s  %s\n", "TIME", "LOCATION", "PROOF", "SENTENCE")
+	for _, s := range slowest[:min(p.top, len(slowest))] {
+		loc := fmt.Sprintf("%s:%d", p.file, s.Range.Start.Line+1)
+		fmt.Fprintf(&sb, "%10s  %-24s  %-24s  %s\n", fmtDuration(s.Duration), loc, proofName(s.proof), oneLine(s.Sentence, 60))
+	}
+
+	type proofTime struct {
+		proof *proofSpan
+		time  time.Duration
+		steps int
+	}
+	var proofs []*proofTime
+	byProof := map[*proofSpan]*proofTime{}
+	for _, s := range p.steps {
+		if s.proof == nil {
+			continue
+		}
+		pt := byProof[s.proof]
+		if pt == nil {
+			pt = &proofTime{proof: s.proof}
+			byProof[s.proof] = pt
+			proofs = append(proofs, pt)
+		}
+		pt.time += s.Duration
+		pt.steps++
+	}
+	slices.SortStableFunc(proofs, func(a, b *proofTime) int { return cmp.Compare(b.time, a.time) })
+	fmt.Fprintf(&sb, "\nTime per proof (%d of %d):\n", min(p.top, len(proofs)), len(proofs))
+	fmt.Fprintf(&sb, "%10s  %5s  %-24s  %s\n", "TIME", "STEPS", "LOCATION", "PROOF")
+	for _, pt := range proofs[:min(p.top, len(proofs))] {
+		loc := fmt.Sprintf("%s:%d", p.file, pt.proof.Range.Start.Line+1)
+		fmt.Fprintf(&sb, "%10s  %5d  %-24s  %s\n", fmtDuration(pt.time), pt.steps, loc, pt.proof.Name)
+	}
+
+	if _, err := io.WriteString(p.w, sb.String()); err != nil {
+		return err
+	}
+	if p.folded != nil {
+		return writeFolded(p.folded, p.steps)
+	}
+	return nil
+}
+
+// writeFolded writes one "proof;sentence microseconds" line per step, the
+// folded-stack format read by flamegraph.pl, inferno and speedscope.
+func writeFolded(w io.Writer, steps []*step) error {
+	var sb strings.Builder
+	for _, s := range steps {
+		frame := fmt.Sprintf("%d: %s", s.Range.Start.Line+1, oneLine(s.Sentence, 80))
+		fmt.Fprintf(&sb, "%s;%s %d\n", foldedFrame(proofName(s.proof)), foldedFrame(frame), s.Duration.Microseconds())
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// foldedFrame makes s usable as a frame name: ';' separates frames, and the
+// count follows the last space.
+func foldedFrame(s string) string {
+	return strings.ReplaceAll(s, ";", "；")
+}
+
+func proofName(p *proofSpan) string {
+	if p == nil {
+		return topLevel
+	}
+	return p.Name
+}
+
+// oneLine collapses whitespace in s and truncates it to n bytes.
+func oneLine(s string, n int) string {
+	s = collapseSpace(s)
+	if len(s) > n {
+		return s[:n] + "…"
+	}
+	return s
+}
+
+// collapseSpace replaces each run of whitespace in s with a single space.
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// fmtDuration renders d in seconds with millisecond precision.
+func fmtDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
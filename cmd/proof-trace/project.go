@@ -0,0 +1,289 @@
+package main
+
+// project.go — "proof-trace --project": tracing every file listed in a
+// _RocqProject in dependency order and reporting the status of each proof.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+// Proof statuses in a project report.
+const (
+	statusProved   = "proved"
+	statusAdmitted = "admitted"
+	statusFailed   = "failed"
+	statusAxioms   = "axiom-dependent" // proved, but relying on axioms
+	statusAborted  = "aborted"
+	statusUnknown  = "unchecked" // the trace stopped before reaching it
+)
+
+// projectReport is the summary of a project trace.
+type projectReport struct {
+	Project string         `json:"project"`
+	Files   []*fileReport  `json:"files"`
+	Totals  map[string]int `json:"totals"` // proofs per status
+	TimeMS  int64          `json:"time_ms"`
+}
+
+// fileReport summarizes the trace of one file.
+type fileReport struct {
+	File   string         `json:"file"` // relative to the project directory
+	Steps  int            `json:"steps"`
+	TimeMS int64          `json:"time_ms"`
+	Error  string         `json:"error,omitempty"` // the file could not be traced
+	Proofs []*proofReport `json:"proofs"`
+}
+
+// proofReport is the outcome of one proof.
+type proofReport struct {
+	Name   string   `json:"name"`
+	Line   int      `json:"line"` // 1-based line of the statement
+	Status string   `json:"status"`
+	Error  string   `json:"error,omitempty"`  // the first error, for failed proofs
+	Axioms []string `json:"axioms,omitempty"` // for axiom-dependent proofs
+	Steps  int      `json:"steps"`
+	TimeMS int64    `json:"time_ms"`
+}
+
+// runProject traces the project at path and writes the table to w and, if
+// jsonPath is set, the JSON report there ("-" for w instead of the table).
+// It reports whether any proof or file failed.
+func runProject(path string, vsrocqArgs []string, w io.Writer, jsonPath string) (bool, error) {
+	p, err := rocq.ReadProject(path)
+	if err != nil {
+		return false, err
+	}
+	files, err := p.DependencyOrder()
+	if err != nil {
+		return false, err
+	}
+
+	sm := rocq.NewStateManager(append(p.Args, vsrocqArgs...))
+	defer sm.Shutdown()
+
+	dir := filepath.Dir(p.Path)
+	report := &projectReport{Project: p.Path, Totals: map[string]int{}}
+	start := time.Now()
+	for _, f := range files {
+		fr := traceProjectFile(sm, f)
+		if rel, err := filepath.Rel(dir, f); err == nil {
+			fr.File = rel
+		}
+		report.Files = append(report.Files, fr)
+		for _, pr := range fr.Proofs {
+			report.Totals[pr.Status]++
+		}
+	}
+	report.TimeMS = time.Since(start).Milliseconds()
+
+	failed := report.Totals[statusFailed] > 0
+	for _, fr := range report.Files {
+		failed = failed || fr.Error != ""
+	}
+
+	if jsonPath == "-" {
+		return failed, writeProjectJSON(w, report)
+	}
+	if jsonPath != "" {
+		f, err := os.Create(jsonPath)
+		if err != nil {
+			return failed, err
+		}
+		defer f.Close()
+		if err := writeProjectJSON(f, report); err != nil {
+			return failed, err
+		}
+	}
+	return failed, writeProjectTable(w, report)
+}
+
+// traceProjectFile traces one file, then asks for the assumptions of each
+// proved proof.
+func traceProjectFile(sm *rocq.StateManager, file string) *fileReport {
+	fr := &fileReport{File: file, Proofs: []*proofReport{}}
+	doc, err := openDoc(sm, file)
+	if err != nil {
+		fr.Error = err.Error()
+		return fr
+	}
+	defer sm.CloseDoc(file)
+
+	var steps []*step
+	start := time.Now()
+	if _, err := trace(sm, doc, func(s *step) error {
+		steps = append(steps, s)
+		return nil
+	}); err != nil {
+		fr.Error = err.Error()
+	}
+	fr.TimeMS = time.Since(start).Milliseconds()
+	fr.Steps = len(steps)
+
+	sm.Mu.Lock()
+	content := doc.Content
+	sm.Mu.Unlock()
+	proofs := findProofs(content)
+	fr.Proofs = proofReports(proofs, steps)
+
+	var proved []*proofSpan
+	for i, p := range proofs {
+		if fr.Proofs[i].Status == statusProved && p.Name != "" {
+			proved = append(proved, p)
+		}
+	}
+	axioms, err := printAssumptions(sm, doc, proved)
+	if err != nil && fr.Error == "" {
+		fr.Error = err.Error()
+	}
+	for i, p := range proofs {
+		if ax := axioms[p]; len(ax) > 0 {
+			fr.Proofs[i].Status = statusAxioms
+			fr.Proofs[i].Axioms = ax
+		}
+	}
+	return fr
+}
+
+// proofReports determines the status of each proof from the steps traced.
+func proofReports(proofs []*proofSpan, steps []*step) []*proofReport {
+	reports := make([]*proofReport, len(proofs))
+	byProof := map[*proofSpan]*proofReport{}
+	for i, p := range proofs {
+		reports[i] = &proofReport{Name: p.Qualified, Line: p.Range.Start.Line + 1}
+		byProof[p] = reports[i]
+	}
+	for _, s := range steps {
+		r := byProof[s.proof]
+		if r == nil {
+			continue
+		}
+		r.Steps++
+		r.TimeMS += s.Duration.Milliseconds()
+		if errs := errorLines(s); len(errs) > 0 && r.Error == "" {
+			r.Error = fmt.Sprintf("line %d: %s", s.Range.Start.Line+1, errs[0])
+		}
+	}
+	for i, p := range proofs {
+		r := reports[i]
+		switch {
+		case r.Error != "":
+			r.Status = statusFailed
+		case r.Steps == 0:
+			r.Status = statusUnknown
+		case p.Status == "open":
+			r.Status, r.Error = statusFailed, "proof not closed"
+		case p.Status == "admitted":
+			r.Status = statusAdmitted
+		case p.Status == "aborted":
+			r.Status = statusAborted
+		default:
+			r.Status = statusProved
+		}
+	}
+	return reports
+}
+
+// printAssumptions appends a "Print Assumptions" query for each proof to the
+// end of the traced document, steps through them, and returns the axioms
+// each proof depends on. The file on disk is not changed.
+func printAssumptions(sm *rocq.StateManager, doc *rocq.DocState, proofs []*proofSpan) (map[*proofSpan][]string, error) {
+	axioms := map[*proofSpan][]string{}
+	if len(proofs) == 0 {
+		return axioms, nil
+	}
+	var queries strings.Builder
+	byQuery := map[string]*proofSpan{}
+	for _, p := range proofs {
+		q := fmt.Sprintf("Print Assumptions %s.", p.Qualified)
+		byQuery[q] = p
+		fmt.Fprintf(&queries, "\n%s", q)
+	}
+
+	sm.Mu.Lock()
+	offset := len(doc.Content)
+	doc.Content += queries.String() + "\n"
+	doc.Version++
+	params := map[string]any{
+		"textDocument":   map[string]any{"uri": doc.URI, "version": doc.Version},
+		"contentChanges": []map[string]any{{"text": doc.Content}},
+	}
+	sm.Mu.Unlock()
+	if err := sm.Client.Notify("textDocument/didChange", params); err != nil {
+		return axioms, fmt.Errorf("didChange: %w", err)
+	}
+
+	_, err := traceFrom(sm, doc, offset, func(s *step) error {
+		if p := byQuery[s.Sentence]; p != nil {
+			axioms[p] = parseAxioms(strings.Join(s.Messages, "\n"))
+		}
+		return nil
+	})
+	return axioms, err
+}
+
+// parseAxioms extracts the axiom names from the output of Print Assumptions:
+// the first words of the unindented lines of its "Axioms:" section. (A long
+// type continues on indented lines, after "name :" or with ": type".)
+func parseAxioms(out string) []string {
+	var names []string
+	inAxioms := false
+	for line := range strings.SplitSeq(out, "\n") {
+		switch {
+		case strings.TrimSpace(line) == "Axioms:":
+			inAxioms = true
+		case strings.HasSuffix(line, ":") && !strings.Contains(line, " :"):
+			inAxioms = false // another section, e.g. "Section Variables:"
+		case inAxioms && line != "" && line[0] != ' ' && line[0] != '\t':
+			name, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+			names = append(names, strings.TrimSuffix(name, ":"))
+		}
+	}
+	return names
+}
+
+func writeProjectJSON(w io.Writer, report *projectReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(report)
+}
+
+// writeProjectTable prints one row per proof, and totals.
+func writeProjectTable(w io.Writer, report *projectReport) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-32s  %-28s  %-15s  %5s  %9s  %s\n", "LOCATION", "PROOF", "STATUS", "STEPS", "TIME", "NOTE")
+	for _, fr := range report.Files {
+		if fr.Error != "" {
+			fmt.Fprintf(&sb, "%-32s  %-28s  %-15s  %5d  %9s  %s\n", fr.File, "", "error", fr.Steps,
+				fmtDuration(time.Duration(fr.TimeMS)*time.Millisecond), oneLine(fr.Error, 100))
+		}
+		for _, pr := range fr.Proofs {
+			note := oneLine(pr.Error, 100)
+			if len(pr.Axioms) > 0 {
+				note = "axioms: " + strings.Join(pr.Axioms, ", ")
+			}
+			loc := fmt.Sprintf("%s:%d", fr.File, pr.Line)
+			fmt.Fprintf(&sb, "%-32s  %-28s  %-15s  %5d  %9s  %s\n", loc, pr.Name, pr.Status, pr.Steps,
+				fmtDuration(time.Duration(pr.TimeMS)*time.Millisecond), note)
+		}
+	}
+
+	var totals []string
+	for _, status := range []string{statusProved, statusAxioms, statusAdmitted, statusFailed, statusAborted, statusUnknown} {
+		if n := report.Totals[status]; n > 0 || status == statusProved || status == statusFailed {
+			totals = append(totals, fmt.Sprintf("%d %s", n, status))
+		}
+	}
+	fmt.Fprintf(&sb, "\n%s in %d files (%s)\n", strings.Join(totals, ", "), len(report.Files),
+		fmtDuration(time.Duration(report.TimeMS)*time.Millisecond))
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
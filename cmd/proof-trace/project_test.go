@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+func TestProofReports(t *testing.T) {
+	content := `Lemma ok : True.
+Proof. exact I. Qed.
+Lemma bad : False.
+Proof. auto. Qed.
+Lemma skipped : True.
+Admitted.
+Module M.
+Lemma inner : True.
+Proof. exact I. Defined.
+End M.
+Lemma late : True.
+Proof. exact I. Qed.
+`
+	proofs := findProofs(content)
+	var steps []*step
+	// Like vsrocq, publish the whole document's diagnostics at each step, so
+	// bad's error is still there for the proofs after it.
+	var diags []rocq.Diagnostic
+	for i, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		if i >= 9 {
+			break // the trace stopped before "late"
+		}
+		r := rocq.Range{Start: rocq.Position{Line: i}, End: rocq.Position{Line: i, Character: len(line)}}
+		if strings.Contains(line, "auto") {
+			diags = append(diags, rocq.Diagnostic{Range: r, Severity: 1, Message: "Attempt to save an incomplete proof"})
+		}
+		s := newStep(i+1, line, r, nil, diags)
+		s.Duration = 10 * time.Millisecond
+		s.proof = proofAt(proofs, r.Start)
+		steps = append(steps, s)
+	}
+
+	var got []string
+	for _, r := range proofReports(proofs, steps) {
+		got = append(got, r.Name+" "+r.Status+" "+r.Error)
+	}
+	want := []string{
+		"ok proved ",
+		"bad failed line 4: Attempt to save an incomplete proof",
+		"skipped admitted ",
+		"M.inner proved ",
+		"late unchecked ",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("proofReports:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestParseAxioms(t *testing.T) {
+	out := `Axioms:
+functional_extensionality_dep
+  : forall (A : Type) (B : A -> Type) (f g : forall x : A, B x),
+    (forall x : A, f x = g x) -> f = g
+classic : forall P : Prop, P \/ ~ P
+propext :
+  forall P Q : Prop, (P <-> Q) -> P = Q
+`
+	if got := parseAxioms(out); !slices.Equal(got, []string{"functional_extensionality_dep", "classic", "propext"}) {
+		t.Errorf("parseAxioms = %q", got)
+	}
+	if got := parseAxioms("Closed under the global context"); len(got) != 0 {
+		t.Errorf("closed proof: %q", got)
+	}
+}
+
+func TestProjectReportOutput(t *testing.T) {
+	report := &projectReport{
+		Project: "/p/_RocqProject",
+		Files: []*fileReport{{File: "theories/A.v", Steps: 6, TimeMS: 1200, Proofs: []*proofReport{
+			{Name: "foo", Line: 3, Status: statusAxioms, Axioms: []string{"classic"}, Steps: 4, TimeMS: 900},
+			{Name: "bar", Line: 9, Status: statusFailed, Error: "line 10: No such goal.", Steps: 2, TimeMS: 300},
+		}}},
+		Totals: map[string]int{statusAxioms: 1, statusFailed: 1},
+		TimeMS: 1250,
+	}
+	var sb strings.Builder
+	if err := writeProjectTable(&sb, report); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"theories/A.v:3                    foo                           axiom-dependent      4     0.900s  axioms: classic",
+		"theories/A.v:9                    bar                           failed               2     0.300s  line 10: No such goal.",
+		"0 proved, 1 axiom-dependent, 1 failed in 1 files (1.250s)",
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("table lacks %q:\n%s", want, sb.String())
+		}
+	}
+
+	sb.Reset()
+	if err := writeProjectJSON(&sb, report); err != nil {
+		t.Fatal(err)
+	}
+	var back projectReport
+	if err := json.Unmarshal([]byte(sb.String()), &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Files[0].Proofs[0].Axioms[0] != "classic" || back.Totals[statusFailed] != 1 {
+		t.Errorf("JSON round trip: %s", sb.String())
+	}
+}
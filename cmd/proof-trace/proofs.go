@@ -0,0 +1,49 @@
+package main
+
+// proofs.go — locating the proof each traced sentence belongs to, from the
+// file's outline.
+
+import "github.com/sanjit/rocq-mcp/internal/rocq"
+
+// proofSpan is a proof in the traced file, as found by rocq.BuildOutline.
+type proofSpan struct {
+	Name      string
+	Qualified string     // Name prefixed with its enclosing modules, as it is known after them
+	Range     rocq.Range // from the statement to Qed/Defined/Admitted/Abort
+	Status    string     // textual status: "proved", "admitted", "aborted" or "open"
+}
+
+// findProofs returns the proofs in content, in file order.
+func findProofs(content string) []*proofSpan {
+	var proofs []*proofSpan
+	var walk func([]*rocq.OutlineEntry, string)
+	walk = func(entries []*rocq.OutlineEntry, prefix string) {
+		for _, e := range entries {
+			if e.Status != "" {
+				proofs = append(proofs, &proofSpan{Name: e.Name, Qualified: prefix + e.Name, Range: e.Range, Status: e.Status})
+			}
+			if e.Kind == "Module" {
+				walk(e.Children, prefix+e.Name+".")
+			} else {
+				walk(e.Children, prefix)
+			}
+		}
+	}
+	walk(rocq.BuildOutline(content), "")
+	return proofs
+}
+
+// proofAt returns the proof containing pos, or nil if pos is outside every proof.
+func proofAt(proofs []*proofSpan, pos rocq.Position) *proofSpan {
+	for _, p := range proofs {
+		if !before(pos, p.Range.Start) && before(pos, p.Range.End) {
+			return p
+		}
+	}
+	return nil
+}
+
+// before reports whether a comes before b.
+func before(a, b rocq.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+}
@@ -0,0 +1,188 @@
+package main
+
+// trace.go — stepping through a document one sentence at a time and
+// collecting the proof state vsrocqtop reports after each.
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+// step is the proof state after executing one sentence.
+type step struct {
+	Index       int               `json:"step"` // 1-based
+	Sentence    string            `json:"sentence"`
+	Range       rocq.Range        `json:"range"`
+	Goals       []goal            `json:"goals"`
+	Background  background        `json:"background"`
+	Messages    []string          `json:"messages"`
+	Diagnostics []rocq.Diagnostic `json:"diagnostics"`
+
+	// Time from sending stepForward to the last notification it produced.
+	Duration time.Duration `json:"-"`
+
+	pv    *rocq.ProofView // as received; nil if no proof view arrived
+	proof *proofSpan      // the proof the sentence belongs to, if any
+}
+
+// goal is a focused goal in structured form.
+type goal struct {
+	ID         string   `json:"id"`
+	Hypotheses []string `json:"hypotheses"`
+	Conclusion string   `json:"conclusion"`
+}
+
+// background counts the goals outside the focus.
+type background struct {
+	Unfocused int `json:"unfocused"`
+	Shelved   int `json:"shelved"`
+	GivenUp   int `json:"given_up"`
+}
+
+// newStep builds the record for a sentence from what vsrocqtop reported.
+// Slices are empty rather than nil, so they encode as [].
+func newStep(index int, sentence string, r rocq.Range, pv *rocq.ProofView, diags []rocq.Diagnostic) *step {
+	s := &step{
+		Index:       index,
+		Sentence:    sentence,
+		Range:       r,
+		Goals:       []goal{},
+		Messages:    []string{},
+		Diagnostics: []rocq.Diagnostic{},
+		pv:          pv,
+	}
+	if pv != nil {
+		for _, g := range pv.Goals {
+			hyps := g.Hypotheses
+			if hyps == nil {
+				hyps = []string{}
+			}
+			s.Goals = append(s.Goals, goal{ID: g.ID, Hypotheses: hyps, Conclusion: g.Conclusion})
+		}
+		s.Background = background{Unfocused: pv.UnfocusedCount, Shelved: pv.ShelvedCount, GivenUp: pv.GivenUpCount}
+		s.Messages = append(s.Messages, pv.Messages...)
+	}
+	s.Diagnostics = append(s.Diagnostics, diags...)
+	return s
+}
+
+// sentenceDiagnostics returns the diagnostics that lie within the step's
+// sentence. vsrocq publishes the whole document's diagnostics each time, so
+// Diagnostics also holds those of earlier sentences.
+func (s *step) sentenceDiagnostics() []rocq.Diagnostic {
+	var diags []rocq.Diagnostic
+	for _, d := range s.Diagnostics {
+		if before(d.Range.Start, s.Range.End) && (before(s.Range.Start, d.Range.End) || d.Range.Start == s.Range.Start) {
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
+
+// trace steps forward through doc until vsrocqtop stops moving, calling emit
+// with each step. It returns the number of steps taken.
+func trace(sm *rocq.StateManager, doc *rocq.DocState, emit func(*step) error) (int, error) {
+	return traceFrom(sm, doc, 0, emit)
+}
+
+// traceFrom is trace for a document whose execution already reached offset.
+func traceFrom(sm *rocq.StateManager, doc *rocq.DocState, offset int, emit func(*step) error) (int, error) {
+	sm.Mu.Lock()
+	content := doc.Content
+	sm.Mu.Unlock()
+	proofs := findProofs(content)
+	prevOffset := offset
+	steps := 0
+
+	for {
+		rocq.DrainChannels(doc)
+
+		sm.Mu.Lock()
+		params := map[string]any{
+			"textDocument": map[string]any{"uri": doc.URI, "version": doc.Version},
+		}
+		sm.Mu.Unlock()
+		sent := time.Now()
+		if err := sm.Client.Notify("prover/stepForward", params); err != nil {
+			return steps, fmt.Errorf("stepForward: %w", err)
+		}
+
+		// Wait for moveCursor, proofView, and diagnostics.
+		var cursorPos *rocq.Position
+		var pv *rocq.ProofView
+		var diags []rocq.Diagnostic
+		var settled time.Time
+
+		timer := time.NewTimer(5 * time.Second)
+		gotCursor := false
+		gotProofView := false
+		gotDiags := false
+
+		for !gotCursor || !gotProofView || !gotDiags {
+			select {
+			case pos := <-doc.CursorCh:
+				cursorPos = &pos
+				gotCursor = true
+			case p := <-doc.ProofViewCh:
+				pv = p
+				gotProofView = true
+			case d := <-doc.DiagnosticCh:
+				diags = d
+				gotDiags = true
+			case <-timer.C:
+				goto done
+			}
+			settled = time.Now()
+			// After first notification, shorten timeout for the rest.
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(500 * time.Millisecond)
+		}
+	done:
+		timer.Stop()
+
+		// No cursor movement means vsrocqtop didn't step — we're at the end.
+		if cursorPos == nil {
+			return steps, nil
+		}
+
+		steps++
+
+		// Extract sentence text from file content.
+		newOffset := rocq.PositionToOffset(content, *cursorPos)
+		sentence := ""
+		start := newOffset
+		if newOffset > prevOffset {
+			text := content[prevOffset:newOffset]
+			sentence = strings.TrimSpace(text)
+			start = newOffset - len(strings.TrimLeftFunc(text, unicode.IsSpace))
+		}
+		prevOffset = newOffset
+
+		r := rocq.Range{Start: rocq.OffsetToPosition(content, start), End: *cursorPos}
+		s := newStep(steps, sentence, r, pv, diags)
+		s.Duration = settled.Sub(sent)
+		s.proof = proofAt(proofs, r.Start)
+		if err := emit(s); err != nil {
+			return steps, err
+		}
+	}
+}
+
+// openDoc opens file and returns its document.
+func openDoc(sm *rocq.StateManager, file string) (*rocq.DocState, error) {
+	if err := sm.OpenDoc(file); err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	return sm.GetDoc(file)
+}
@@ -60,6 +60,36 @@ func TestE2EMCPProofSession(t *testing.T) {
 		}
 	}
 
+	// List prompts.
+	prompts, err := session.ListPrompts(ctx, nil)
+	if err != nil {
+		t.Fatalf("list prompts: %v", err)
+	}
+	promptNames := make(map[string]bool)
+	for _, p := range prompts.Prompts {
+		promptNames[p.Name] = true
+	}
+	for _, name := range []string{"fix_first_error", "prove_lemma", "explain_goal"} {
+		if !promptNames[name] {
+			t.Errorf("missing prompt: %s", name)
+		}
+	}
+
+	// List resource templates.
+	templates, err := session.ListResourceTemplates(ctx, nil)
+	if err != nil {
+		t.Fatalf("list resource templates: %v", err)
+	}
+	templateURIs := make(map[string]bool)
+	for _, rt := range templates.ResourceTemplates {
+		templateURIs[rt.URITemplate] = true
+	}
+	for _, uri := range []string{"rocq://goals/{+path}", "rocq://diagnostics/{+path}"} {
+		if !templateURIs[uri] {
+			t.Errorf("missing resource template: %s", uri)
+		}
+	}
+
 	// Open file.
 	res, err := session.CallTool(ctx, &mcp.CallToolParams{
 		Name:      "rocq_open",
@@ -87,6 +117,15 @@ func TestE2EMCPProofSession(t *testing.T) {
 		t.Errorf("expected goal '0 + n = n', got:\n%s", text)
 	}
 
+	// The goals resource reflects the last check.
+	goals, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "rocq://goals" + absPath})
+	if err != nil {
+		t.Fatalf("read goals resource: %v", err)
+	}
+	if len(goals.Contents) == 0 || !strings.Contains(goals.Contents[0].Text, "0 + n = n") {
+		t.Errorf("expected goal '0 + n = n' in resource, got: %+v", goals.Contents)
+	}
+
 	// Check all (should complete cleanly).
 	res, err = session.CallTool(ctx, &mcp.CallToolParams{
 		Name:      "rocq_check_all",
@@ -0,0 +1,101 @@
+package rocq
+
+// checkpoint.go — named snapshots of document state for exploratory proving.
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Checkpoint is a saved document state that can be restored later.
+type Checkpoint struct {
+	Content string   // document content as vsrocq saw it
+	Disk    string   // file contents on disk
+	ExecPos Position // execution point to re-check to on restore
+}
+
+// checkpointNames returns the sorted names of a document's checkpoints.
+func checkpointNames(doc *DocState) []string {
+	var names []string
+	for name := range doc.Checkpoints {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// DoCheckpoint records the document's content, on-disk contents and execution
+// point under name, replacing any checkpoint of the same name.
+func DoCheckpoint(sm *StateManager, file, name string) (*mcp.CallToolResult, any, error) {
+	if name == "" {
+		return ErrResult(fmt.Errorf("checkpoint name must not be empty")), nil, nil
+	}
+	disk, err := sm.readFile(file)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+
+	doc, release, err := sm.acquireDoc(file)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	defer release()
+
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	if doc.Checkpoints == nil {
+		doc.Checkpoints = make(map[string]*Checkpoint)
+	}
+	if lost := sm.lostCheckpoints[doc.URI]; slices.Contains(lost, name) {
+		sm.lostCheckpoints[doc.URI] = slices.DeleteFunc(slices.Clone(lost), func(n string) bool { return n == name })
+	}
+	doc.Checkpoints[name] = &Checkpoint{
+		Content: doc.Content,
+		Disk:    string(disk),
+		ExecPos: doc.ExecPos,
+	}
+	return TextResult(fmt.Sprintf("Saved checkpoint %q at line %d:%d (checkpoints: %s)",
+		name, doc.ExecPos.Line+1, doc.ExecPos.Character, strings.Join(checkpointNames(doc), ", "))), nil, nil
+}
+
+// DoRestore writes a checkpoint's contents back to disk, sends them to vsrocq,
+// and re-checks to the saved execution point, returning the restored goals.
+func DoRestore(sm *StateManager, file, name string) (*mcp.CallToolResult, any, error) {
+	doc, release, err := sm.acquireProver(file)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	defer release()
+
+	sm.Mu.Lock()
+	cp, ok := doc.Checkpoints[name]
+	if !ok {
+		if err := sm.lostCheckpointError(doc, name); err != nil {
+			sm.Mu.Unlock()
+			return ErrResult(err), nil, nil
+		}
+		names := checkpointNames(doc)
+		sm.Mu.Unlock()
+		return ErrResult(fmt.Errorf("no checkpoint %q (checkpoints: %s)", name, strings.Join(names, ", "))), nil, nil
+	}
+	sm.Mu.Unlock()
+
+	if err := sm.writeFile(file, []byte(cp.Disk)); err != nil {
+		return ErrResult(err), nil, nil
+	}
+	if err := sm.changeDoc(doc, cp.Content); err != nil {
+		return ErrResult(err), nil, nil
+	}
+
+	result, _, _ := checkAt(sm, doc, cp.ExecPos)
+	if result.IsError {
+		return result, nil, nil
+	}
+	header := &mcp.TextContent{Text: fmt.Sprintf("Restored checkpoint %q; checked to line %d:%d.\n",
+		name, cp.ExecPos.Line+1, cp.ExecPos.Character)}
+	result.Content = append([]mcp.Content{header}, result.Content...)
+	return result, nil, nil
+}
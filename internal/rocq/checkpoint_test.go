@@ -0,0 +1,37 @@
+package rocq
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointRecordsState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.v")
+	if err := os.WriteFile(path, []byte("on disk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := NewStateManager(nil)
+	doc := &DocState{URI: FileURI(path), Content: "in editor", ExecPos: Position{Line: 2, Character: 4}, ops: make(chan struct{}, 1)}
+	sm.Docs[doc.URI] = doc
+
+	result, _, _ := DoCheckpoint(sm, path, "before-refactor")
+	if result.IsError {
+		t.Fatalf("DoCheckpoint: %s", resultText(result))
+	}
+	cp := doc.Checkpoints["before-refactor"]
+	if cp == nil {
+		t.Fatal("checkpoint not recorded")
+	}
+	want := Checkpoint{Content: "in editor", Disk: "on disk", ExecPos: Position{Line: 2, Character: 4}}
+	if *cp != want {
+		t.Errorf("got %+v, want %+v", *cp, want)
+	}
+
+	result, _, _ = DoRestore(sm, path, "missing")
+	if !result.IsError || !strings.Contains(resultText(result), "before-refactor") {
+		t.Errorf("expected error listing checkpoints, got: %s", resultText(result))
+	}
+}
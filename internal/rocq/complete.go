@@ -0,0 +1,172 @@
+package rocq
+
+// complete.go — identifier completion via textDocument/completion, with a Search fallback.
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxCompletions bounds the number of candidates returned by rocq_complete.
+const maxCompletions = 30
+
+// completionKinds names LSP CompletionItemKind values.
+var completionKinds = map[int]string{
+	1: "text", 2: "method", 3: "function", 4: "constructor", 5: "field",
+	6: "variable", 7: "class", 8: "interface", 9: "module", 10: "property",
+	12: "value", 13: "enum", 14: "keyword", 15: "snippet", 20: "enum member",
+	21: "constant", 22: "struct", 25: "type parameter",
+}
+
+// ParseCompletions decodes a textDocument/completion result, either a
+// CompletionList or a bare CompletionItem array.
+func ParseCompletions(raw json.RawMessage) []Completion {
+	type item struct {
+		Label  string `json:"label"`
+		Kind   int    `json:"kind"`
+		Detail string `json:"detail"`
+	}
+	var items []item
+	if json.Unmarshal(raw, &items) != nil {
+		var list struct {
+			Items []item `json:"items"`
+		}
+		if json.Unmarshal(raw, &list) != nil {
+			return nil
+		}
+		items = list.Items
+	}
+	var out []Completion
+	for _, it := range items {
+		kind := completionKinds[it.Kind]
+		if kind == "" {
+			kind = "unknown"
+		}
+		out = append(out, Completion{Name: it.Label, Kind: kind, Type: it.Detail})
+	}
+	return out
+}
+
+// prefixSearchQuery builds a Search query that finds names containing the last
+// component of prefix, restricted to its module qualifier if it has one.
+func prefixSearchQuery(prefix string) string {
+	module, base := "", prefix
+	if i := strings.LastIndex(prefix, "."); i >= 0 {
+		module, base = prefix[:i], prefix[i+1:]
+	}
+	query := fmt.Sprintf("%q", base)
+	if module != "" {
+		query += " inside " + module
+	}
+	return query
+}
+
+// matchesPrefix reports whether name completes prefix, either as a whole or
+// on the unqualified part of each.
+func matchesPrefix(name, prefix string) bool {
+	if strings.HasPrefix(name, prefix) {
+		return true
+	}
+	base := prefix[strings.LastIndex(prefix, ".")+1:]
+	short := name[strings.LastIndex(name, ".")+1:]
+	return !strings.Contains(prefix, ".") && strings.HasPrefix(short, base)
+}
+
+// RankCompletions sorts candidates in place by scope distance: names declared
+// in the current file first, then names needing fewer module qualifiers beyond
+// what was typed, then shorter names, then alphabetically.
+func RankCompletions(cands []Completion, prefix string, local map[string]bool) {
+	typed := strings.Count(prefix, ".")
+	distance := func(c Completion) int {
+		if local[c.Name] {
+			return -1
+		}
+		return strings.Count(c.Name, ".") - typed
+	}
+	slices.SortStableFunc(cands, func(a, b Completion) int {
+		return cmp.Or(
+			cmp.Compare(distance(a), distance(b)),
+			cmp.Compare(len(a.Name), len(b.Name)),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+}
+
+// FormatCompletions renders ranked candidates, one per line.
+func FormatCompletions(cands []Completion, prefix string) string {
+	if len(cands) == 0 {
+		return fmt.Sprintf("No completions for %q.", prefix)
+	}
+	var sb strings.Builder
+	shown := min(len(cands), maxCompletions)
+	fmt.Fprintf(&sb, "=== Completions for %q: %d of %d ===\n", prefix, shown, len(cands))
+	for _, c := range cands[:shown] {
+		if c.Type != "" {
+			fmt.Fprintf(&sb, "%s (%s) : %s\n", c.Name, c.Kind, c.Type)
+		} else {
+			fmt.Fprintf(&sb, "%s (%s)\n", c.Name, c.Kind)
+		}
+	}
+	return sb.String()
+}
+
+var prefixRe = regexp.MustCompile(`[A-Za-z_][\w'.]*$`)
+
+// DoComplete returns identifiers completing prefix at the given position, from
+// textDocument/completion or, if that yields nothing, from a Search on the name.
+// An empty prefix is taken from the text before the position.
+func DoComplete(sm *StateManager, file string, line, col int, prefix string) (*mcp.CallToolResult, any, error) {
+	doc, release, err := sm.acquireDoc(file)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	defer release()
+
+	sm.Mu.Lock()
+	content := doc.Content
+	sm.Mu.Unlock()
+
+	pos := Position{Line: line, Character: col}
+	if prefix == "" {
+		prefix = prefixRe.FindString(content[:PositionToOffset(content, pos)])
+	}
+	if prefix == "" {
+		return ErrResult(fmt.Errorf("no prefix given or found before line %d:%d", line+1, col)), nil, nil
+	}
+
+	var cands []Completion
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": doc.URI},
+		"position":     map[string]any{"line": line, "character": col},
+	}
+	if result, err := sm.Client.Request("textDocument/completion", params); err == nil {
+		for _, c := range ParseCompletions(result) {
+			if matchesPrefix(c.Name, prefix) {
+				cands = append(cands, c)
+			}
+		}
+	}
+
+	if len(cands) == 0 {
+		results, err := runSearch(sm, doc, pos, prefixSearchQuery(prefix))
+		if err != nil {
+			return ErrResult(err), nil, nil
+		}
+		seen := make(map[string]bool)
+		for _, r := range results {
+			if matchesPrefix(r.Name, prefix) && !seen[r.Name] {
+				seen[r.Name] = true
+				cands = append(cands, Completion{Name: r.Name, Kind: "lemma", Type: r.Statement})
+			}
+		}
+	}
+
+	RankCompletions(cands, prefix, localDeclNames(content))
+	return TextResult(FormatCompletions(cands, prefix)), nil, nil
+}
@@ -0,0 +1,82 @@
+package rocq
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseCompletions(t *testing.T) {
+	list := `{"isIncomplete":false,"items":[{"label":"Nat.add_comm","kind":21,"detail":"forall n m : nat, n + m = m + n"},{"label":"S","kind":4}]}`
+	got := ParseCompletions(json.RawMessage(list))
+	want := []Completion{
+		{Name: "Nat.add_comm", Kind: "constant", Type: "forall n m : nat, n + m = m + n"},
+		{Name: "S", Kind: "constructor"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("item %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if got := ParseCompletions(json.RawMessage(`[{"label":"x","kind":99}]`)); len(got) != 1 || got[0].Kind != "unknown" {
+		t.Errorf("bare array: got %+v", got)
+	}
+}
+
+func TestPrefixSearchQuery(t *testing.T) {
+	if got := prefixSearchQuery("add_co"); got != `"add_co"` {
+		t.Errorf("got %s", got)
+	}
+	if got := prefixSearchQuery("Nat.add_co"); got != `"add_co" inside Nat` {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestMatchesPrefix(t *testing.T) {
+	tests := []struct {
+		name, prefix string
+		want         bool
+	}{
+		{"Nat.add_comm", "Nat.add_c", true},
+		{"Nat.add_comm", "add_c", true},
+		{"Nat.add_comm", "List.add", false},
+		{"Nat.mul_comm", "add", false},
+	}
+	for _, tt := range tests {
+		if got := matchesPrefix(tt.name, tt.prefix); got != tt.want {
+			t.Errorf("matchesPrefix(%q, %q) = %v, want %v", tt.name, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestRankCompletions(t *testing.T) {
+	cands := []Completion{
+		{Name: "Stdlib.Arith.PeanoNat.Nat.add_comm"},
+		{Name: "Nat.add_comm"},
+		{Name: "add_comm_local"},
+		{Name: "add_comm"},
+	}
+	RankCompletions(cands, "add_c", map[string]bool{"add_comm_local": true})
+	want := []string{"add_comm_local", "add_comm", "Nat.add_comm", "Stdlib.Arith.PeanoNat.Nat.add_comm"}
+	for i, name := range want {
+		if cands[i].Name != name {
+			t.Errorf("position %d: got %s, want %s", i, cands[i].Name, name)
+		}
+	}
+}
+
+func TestFormatCompletions(t *testing.T) {
+	got := FormatCompletions([]Completion{
+		{Name: "Nat.add_comm", Kind: "lemma", Type: "forall n m : nat, n + m = m + n"},
+		{Name: "Nat", Kind: "module"},
+	}, "Na")
+	want := `=== Completions for "Na": 2 of 2 ===
+Nat.add_comm (lemma) : forall n m : nat, n + m = m + n
+Nat (module)
+`
+	if got != want {
+		t.Errorf("mismatch.\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
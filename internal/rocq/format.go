@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -59,6 +61,11 @@ func FormatBackgroundCounts(pv *ProofView) string {
 
 // FormatFullResults formats the complete proof state.
 func FormatFullResults(pv *ProofView, diags []Diagnostic) *mcp.CallToolResult {
+	return TextResult(FormatProofState(pv, diags))
+}
+
+// FormatProofState renders goals, background counts, messages and diagnostics.
+func FormatProofState(pv *ProofView, diags []Diagnostic) string {
 	var sb strings.Builder
 
 	if pv != nil {
@@ -93,7 +100,7 @@ func FormatFullResults(pv *ProofView, diags []Diagnostic) *mcp.CallToolResult {
 		sb.WriteString("No goals or diagnostics.")
 	}
 
-	return TextResult(sb.String())
+	return sb.String()
 }
 
 // FormatDiagnostics appends diagnostic output to a string builder.
@@ -157,7 +164,7 @@ func ParseProofView(params json.RawMessage) *ProofView {
 		for _, h := range g.Hypotheses {
 			hyps = append(hyps, RenderPpcmd(h))
 		}
-		pv.Goals = append(pv.Goals, Goal{ID: id, Text: RenderGoalText(hyps, conclusion)})
+		pv.Goals = append(pv.Goals, Goal{ID: id, Text: RenderGoalText(hyps, conclusion), Hypotheses: hyps, Conclusion: conclusion})
 	}
 
 	for _, m := range raw.Messages {
@@ -192,6 +199,222 @@ func ParseProofView(params json.RawMessage) *ProofView {
 	return pv
 }
 
+var (
+	stateIDRe     = regexp.MustCompile(`^\[([^\]]*)\]\s*`)
+	stateRangeRe  = regexp.MustCompile(`\(\s*(\d+)\s*(?:->|-|–|,)\s*(\d+)\s*\)`)
+	stateStatusRe = regexp.MustCompile(`\(([A-Za-z][A-Za-z ]*)\)\s*$`)
+)
+
+// ParseDocumentState parses the prover/documentState dump, as printed by
+// vsrocq's DocumentManager.Internal.string_of_state: one item per line, in
+// document order, each "[id] tokens (start -> stop) (status)" where tokens are
+// the sentence's tokens joined by spaces and start and stop are offsets.
+// Parsing errors print "[parsing error]" in place of the ID, and comments are
+// items too. Every part but the text is optional, so unrecognized lines are
+// kept verbatim with an empty status.
+//
+// vsrocq reports a sentence that failed as executed; MarkSentenceErrors
+// corrects that from the diagnostics.
+func ParseDocumentState(dump string) []SentenceState {
+	var sentences []SentenceState
+	for line := range strings.SplitSeq(dump, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		s := SentenceState{Start: -1, End: -1}
+		if m := stateIDRe.FindStringSubmatch(line); m != nil {
+			s.ID = m[1]
+			line = line[len(m[0]):]
+		}
+		parseError := s.ID == "parsing error"
+		if parseError {
+			s.ID = ""
+		}
+		if m := stateStatusRe.FindStringSubmatchIndex(line); m != nil {
+			s.Status = normalizeSentenceStatus(line[m[2]:m[3]])
+			line = line[:m[0]]
+		}
+		if m := stateRangeRe.FindStringSubmatchIndex(line); m != nil {
+			s.Start, _ = strconv.Atoi(line[m[2]:m[3]])
+			s.End, _ = strconv.Atoi(line[m[4]:m[5]])
+			line = line[:m[0]] + line[m[1]:]
+		}
+		s.Text = strings.TrimSpace(line)
+		if s.Status == "comment" {
+			continue
+		}
+		if parseError {
+			s.Status = "error"
+		}
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// normalizeSentenceStatus maps the statuses vsrocq prints onto "executed",
+// "unprocessed", "error" or "comment". Any other status is reported as
+// "unknown (status)".
+func normalizeSentenceStatus(status string) string {
+	status = strings.ToLower(strings.TrimSpace(status))
+	switch status {
+	case "executed", "executed in worker":
+		return "executed"
+	case "not executed":
+		return "unprocessed"
+	case "error", "parsing error":
+		return "error"
+	case "comment":
+		return "comment"
+	}
+	return fmt.Sprintf("unknown (%s)", status)
+}
+
+// MarkSentenceErrors sets the status of executed sentences that an error
+// diagnostic falls in to "error".
+func MarkSentenceErrors(content string, sentences []SentenceState, diags []Diagnostic) {
+	for i := range sentences {
+		s := &sentences[i]
+		if s.Status == "executed" && errorDiagnosticIn(content, *s, diags) {
+			s.Status = "error"
+		}
+	}
+}
+
+// errorDiagnosticIn reports whether an error diagnostic starts within s.
+func errorDiagnosticIn(content string, s SentenceState, diags []Diagnostic) bool {
+	if s.Start < 0 || s.End < s.Start {
+		return false
+	}
+	start, end := OffsetToPosition(content, s.Start), OffsetToPosition(content, s.End)
+	for _, d := range diags {
+		p := d.Range.Start
+		if d.Severity == 1 && !positionLess(p, start) && positionLess(p, end) {
+			return true
+		}
+	}
+	return false
+}
+
+func positionLess(a, b Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+}
+
+// FormatDocumentState renders parsed document state as a readable summary:
+// each sentence with its status, error spans, and the unprocessed regions.
+func FormatDocumentState(content string, sentences []SentenceState, diags []Diagnostic) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "=== Sentences: %d ===\n", len(sentences))
+	lastEnd, ranged := 0, len(sentences) == 0
+	for _, s := range sentences {
+		status := s.Status
+		if status == "" {
+			status = "?"
+		}
+		if s.Start >= 0 && s.End >= s.Start {
+			start := OffsetToPosition(content, s.Start)
+			end := OffsetToPosition(content, s.End)
+			text := s.Text // vsrocq's tokens; the source reads better
+			if s.End <= len(content) {
+				text = strings.Join(strings.Fields(content[s.Start:s.End]), " ")
+			}
+			fmt.Fprintf(&sb, "  L%d–%d [%s] %s\n", start.Line+1, end.Line+1, status, text)
+			lastEnd, ranged = max(lastEnd, s.End), true
+		} else {
+			fmt.Fprintf(&sb, "  [%s] %s\n", status, s.Text)
+		}
+	}
+
+	// Errors: the diagnostics, and parsing errors, which have none.
+	var errs []string
+	for _, s := range sentences {
+		if s.Status == "error" && s.Start >= 0 && !errorDiagnosticIn(content, s, diags) {
+			start := OffsetToPosition(content, s.Start)
+			end := OffsetToPosition(content, s.End)
+			errs = append(errs, fmt.Sprintf("  line %d:%d–%d:%d: %s",
+				start.Line+1, start.Character, end.Line+1, end.Character, s.Text))
+		}
+	}
+	for _, d := range diags {
+		if d.Severity == 1 {
+			errs = append(errs, fmt.Sprintf("  line %d:%d–%d:%d: %s",
+				d.Range.Start.Line+1, d.Range.Start.Character,
+				d.Range.End.Line+1, d.Range.End.Character, d.Message))
+		}
+	}
+	if len(errs) > 0 {
+		sb.WriteString("\n=== Errors ===\n")
+		for _, e := range errs {
+			sb.WriteString(e + "\n")
+		}
+	}
+
+	// Group runs of consecutive unprocessed sentences into line ranges.
+	var unprocessed []string
+	runStart, runEnd, runLen := -1, -1, 0
+	flush := func() {
+		if runLen > 0 {
+			unprocessed = append(unprocessed, fmt.Sprintf("  lines %d–%d (%d sentences not executed)",
+				OffsetToPosition(content, runStart).Line+1, OffsetToPosition(content, runEnd).Line+1, runLen))
+		}
+		runStart, runEnd, runLen = -1, -1, 0
+	}
+	for _, s := range sentences {
+		if s.Status != "unprocessed" || s.Start < 0 {
+			flush()
+			continue
+		}
+		if runLen == 0 {
+			runStart = s.Start
+		}
+		runEnd = s.End
+		runLen++
+	}
+	flush()
+	if rest := content[min(lastEnd, len(content)):]; ranged && strings.TrimSpace(rest) != "" {
+		startOff := len(content) - len(strings.TrimLeft(rest, " \t\r\n"))
+		start := OffsetToPosition(content, startOff)
+		end := OffsetToPosition(content, len(strings.TrimRight(content, " \t\r\n")))
+		unprocessed = append(unprocessed, fmt.Sprintf("  lines %d–%d (not yet parsed)", start.Line+1, end.Line+1))
+	}
+	if len(unprocessed) > 0 {
+		sb.WriteString("\n=== Unprocessed ===\n")
+		for _, u := range unprocessed {
+			sb.WriteString(u + "\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// PositionToOffset converts an LSP Position to a byte offset in content.
+// Positions past the end of a line or of content are clamped.
+func PositionToOffset(content string, pos Position) int {
+	offset := 0
+	for range pos.Line {
+		i := strings.IndexByte(content[offset:], '\n')
+		if i < 0 {
+			return len(content)
+		}
+		offset += i + 1
+	}
+	lineEnd := strings.IndexByte(content[offset:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(content) - offset
+	}
+	return offset + min(max(pos.Character, 0), lineEnd)
+}
+
+// OffsetToPosition converts a byte offset in content to an LSP Position.
+// Offsets past the end are clamped to the end of content.
+func OffsetToPosition(content string, offset int) Position {
+	offset = min(max(offset, 0), len(content))
+	line := strings.Count(content[:offset], "\n")
+	lineStart := strings.LastIndex(content[:offset], "\n") + 1
+	return Position{Line: line, Character: offset - lineStart}
+}
+
 type rawGoal struct {
 	ID         json.RawMessage   `json:"id"`
 	Goal       json.RawMessage   `json:"goal"`
@@ -262,6 +485,17 @@ func RenderPpcmd(raw json.RawMessage) string {
 	return ""
 }
 
+// ResultText joins the text content of a tool result.
+func ResultText(r *mcp.CallToolResult) string {
+	var parts []string
+	for _, c := range r.Content {
+		if tc, ok := c.(*mcp.TextContent); ok {
+			parts = append(parts, tc.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
 // TextResult wraps a string in an MCP CallToolResult.
 func TextResult(text string) *mcp.CallToolResult {
 	return &mcp.CallToolResult{
@@ -168,3 +168,90 @@ func TestRenderGoalText_NoHypotheses(t *testing.T) {
 		t.Errorf("mismatch.\nwant:\n%s\ngot:\n%s", want, got)
 	}
 }
+
+func TestParseDocumentState(t *testing.T) {
+	// The documentState dump for testdata/error.v checked to the end, as
+	// DocumentManager.Internal.string_of_state prints it (the failed exact is
+	// "executed"), followed by items of the other kinds it prints.
+	content := "Theorem bad : True.\nProof.\n  exact 42.\nQed.\n"
+	dump := "[1] Theorem bad : True . (0 -> 19) (executed)\n" +
+		"[2] Proof . (20 -> 26) (executed)\n" +
+		"[3] exact 42 . (29 -> 38) (executed)\n" +
+		"[4] Qed . (39 -> 43) (not executed)\n" +
+		"\n" +
+		"(* comment *) (44 -> 57) (comment)\n" +
+		"[5] Check bad . (58 -> 68) (executed in worker)\n" +
+		"[parsing error] [Syntax error: '.' expected.] (69 -> 75) (error)\n" +
+		"[6] Print bad . (76 -> 86) (queued)\n" +
+		"garbage line\n"
+	got := ParseDocumentState(dump)
+	diags := []Diagnostic{{
+		Range:    Range{Start: Position{Line: 2, Character: 8}, End: Position{Line: 2, Character: 10}},
+		Severity: 1, Message: "The term \"42\" has type \"nat\" while it is expected to have type \"True\".",
+	}}
+	MarkSentenceErrors(content, got, diags)
+	want := []SentenceState{
+		{ID: "1", Text: "Theorem bad : True .", Start: 0, End: 19, Status: "executed"},
+		{ID: "2", Text: "Proof .", Start: 20, End: 26, Status: "executed"},
+		{ID: "3", Text: "exact 42 .", Start: 29, End: 38, Status: "error"},
+		{ID: "4", Text: "Qed .", Start: 39, End: 43, Status: "unprocessed"},
+		{ID: "5", Text: "Check bad .", Start: 58, End: 68, Status: "executed"},
+		{Text: "[Syntax error: '.' expected.]", Start: 69, End: 75, Status: "error"},
+		{ID: "6", Text: "Print bad .", Start: 76, End: 86, Status: "unknown (queued)"},
+		{Text: "garbage line", Start: -1, End: -1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d sentences, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sentence %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFormatDocumentState(t *testing.T) {
+	content := "Theorem t : True.\nProof.\n  exact 42.\nQed.\nCheck t.\n"
+	sentences := []SentenceState{
+		{ID: "1", Text: "Theorem t : True.", Start: 0, End: 17, Status: "executed"},
+		{ID: "2", Text: "Proof.", Start: 18, End: 24, Status: "executed"},
+		{ID: "3", Text: "exact 42.", Start: 27, End: 36, Status: "error"},
+		{ID: "4", Text: "Qed.", Start: 37, End: 41, Status: "unprocessed"},
+	}
+	got := FormatDocumentState(content, sentences, nil)
+	want := `=== Sentences: 4 ===
+  L1–1 [executed] Theorem t : True.
+  L2–2 [executed] Proof.
+  L3–3 [error] exact 42.
+  L4–4 [unprocessed] Qed.
+
+=== Errors ===
+  line 3:2–3:11: exact 42.
+
+=== Unprocessed ===
+  lines 4–4 (1 sentences not executed)
+  lines 5–5 (not yet parsed)
+`
+	if got != want {
+		t.Errorf("mismatch.\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestOffsetToPosition(t *testing.T) {
+	content := "Lemma a.\n  intros.\n"
+	for _, off := range []int{0, 5, 9, 11, len(content)} {
+		if got := PositionToOffset(content, OffsetToPosition(content, off)); got != off {
+			t.Errorf("round trip of %d: %d", off, got)
+		}
+	}
+	if p := OffsetToPosition(content, 11); p != (Position{Line: 1, Character: 2}) {
+		t.Errorf("OffsetToPosition(11) = %+v", p)
+	}
+	// Stale positions past a line or the content clamp instead of overrunning.
+	if got := PositionToOffset(content, Position{Line: 0, Character: 40}); got != 8 {
+		t.Errorf("past end of line: %d, want 8", got)
+	}
+	if got := PositionToOffset(content, Position{Line: 5, Character: 3}); got != len(content) {
+		t.Errorf("past end of content: %d, want %d", got, len(content))
+	}
+}
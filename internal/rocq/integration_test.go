@@ -16,13 +16,13 @@ func testdataPath(file string) string {
 }
 
 func TestVsrocqInitShutdown(t *testing.T) {
-	client, err := newVsrocqClient(nil)
+	client, err := newVsrocqClient(nil, nil)
 	if err != nil {
 		t.Fatalf("newVsrocqClient: %v", err)
 	}
 
 	cwd, _ := os.Getwd()
-	if err := client.initialize("file://" + cwd); err != nil {
+	if err := client.initialize([]string{cwd}); err != nil {
 		t.Fatalf("initialize: %v", err)
 	}
 
@@ -133,6 +133,89 @@ func TestCheckProofGoals(t *testing.T) {
 	}
 }
 
+func TestCheckProofByName(t *testing.T) {
+	sm := NewStateManager(nil)
+	defer sm.Shutdown()
+
+	path := testdataPath("simple.v")
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatalf("OpenDoc: %v", err)
+	}
+
+	result, _, _ := DoCheckProof(sm, path, "plus_0_n", "step", 1)
+	text := resultText(result)
+	t.Logf("check_proof result:\n%s", text)
+	if !strings.Contains(text, "> 1. L3: intros n.") {
+		t.Errorf("expected tactic list marking intros, got:\n%s", text)
+	}
+	if !strings.Contains(text, "0 + n = n") {
+		t.Errorf("expected goal '0 + n = n', got:\n%s", text)
+	}
+}
+
+func TestFixFirstErrorPrompt(t *testing.T) {
+	sm := NewStateManager(nil)
+	defer sm.Shutdown()
+
+	path := testdataPath("error.v")
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatalf("OpenDoc: %v", err)
+	}
+
+	text, err := FixFirstErrorPrompt(sm, path)
+	if err != nil {
+		t.Fatalf("FixFirstErrorPrompt: %v", err)
+	}
+	t.Logf("prompt:\n%s", text)
+	if !strings.Contains(text, "Error at line 3") {
+		t.Errorf("expected error on line 3, got:\n%s", text)
+	}
+	if !strings.Contains(text, "exact 42.") {
+		t.Errorf("expected source excerpt, got:\n%s", text)
+	}
+}
+
+func TestCheckpointRestore(t *testing.T) {
+	sm := NewStateManager(nil)
+	defer sm.Shutdown()
+
+	orig, err := os.ReadFile(testdataPath("simple.v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "simple.v")
+	if err := os.WriteFile(path, orig, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatalf("OpenDoc: %v", err)
+	}
+
+	DoCheck(sm, path, 3, 0)
+	DoCheckpoint(sm, path, "intros")
+
+	// A risky edit, synced and checked.
+	broken := strings.Replace(string(orig), "reflexivity.", "exact 42.", 1)
+	if err := os.WriteFile(path, []byte(broken), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.SyncDoc(path); err != nil {
+		t.Fatalf("SyncDoc: %v", err)
+	}
+	DoCheckAll(sm, path)
+
+	result, _, _ := DoRestore(sm, path, "intros")
+	text := resultText(result)
+	t.Logf("restore result:\n%s", text)
+	if !strings.Contains(text, "0 + n = n") {
+		t.Errorf("expected restored goal '0 + n = n', got:\n%s", text)
+	}
+	disk, _ := os.ReadFile(path)
+	if string(disk) != string(orig) {
+		t.Errorf("file on disk not restored:\n%s", disk)
+	}
+}
+
 func TestQueryAbout(t *testing.T) {
 	sm := NewStateManager(nil)
 	defer sm.Shutdown()
@@ -144,7 +227,7 @@ func TestQueryAbout(t *testing.T) {
 
 	DoCheckAll(sm, path)
 
-	result, _, _ := DoQuery(sm, path, "prover/about", "Nat.add")
+	result, _, _ := DoQuery(sm, path, "prover/about", "Nat.add", nil)
 	text := resultText(result)
 	t.Logf("about result:\n%s", text)
 	if text == "" || text == "No result." {
@@ -163,7 +246,7 @@ func TestQueryCheckType(t *testing.T) {
 
 	DoCheckAll(sm, path)
 
-	result, _, _ := DoQuery(sm, path, "prover/check", "Nat.add")
+	result, _, _ := DoQuery(sm, path, "prover/check", "Nat.add", nil)
 	text := resultText(result)
 	t.Logf("check type result:\n%s", text)
 	if text == "" || text == "No result." {
@@ -182,7 +265,7 @@ func TestQueryLocate(t *testing.T) {
 
 	DoCheckAll(sm, path)
 
-	result, _, _ := DoQuery(sm, path, "prover/locate", "Nat.add")
+	result, _, _ := DoQuery(sm, path, "prover/locate", "Nat.add", nil)
 	text := resultText(result)
 	t.Logf("locate result:\n%s", text)
 	if text == "" || text == "No result." {
@@ -201,7 +284,7 @@ func TestQueryPrint(t *testing.T) {
 
 	DoCheckAll(sm, path)
 
-	result, _, _ := DoQuery(sm, path, "prover/print", "Nat.add")
+	result, _, _ := DoQuery(sm, path, "prover/print", "Nat.add", nil)
 	text := resultText(result)
 	t.Logf("print result:\n%s", text)
 	if text == "" || text == "No result." {
@@ -209,6 +292,37 @@ func TestQueryPrint(t *testing.T) {
 	}
 }
 
+func TestQueryHypothesis(t *testing.T) {
+	sm := NewStateManager(nil)
+	defer sm.Shutdown()
+
+	path := testdataPath("simple.v")
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatalf("OpenDoc: %v", err)
+	}
+
+	// After "intros n.", the hypothesis n is in the focused goal's context.
+	DoCheck(sm, path, 3, 0)
+
+	result, _, _ := DoQuery(sm, path, "prover/check", "n", nil)
+	text := resultText(result)
+	t.Logf("check n at execution point:\n%s", text)
+	if !strings.Contains(text, "nat") {
+		t.Errorf("expected hypothesis n : nat, got:\n%s", text)
+	}
+
+	result, _, _ = DoQuery(sm, path, "prover/check", "plus_0_n", &Position{Line: 5, Character: 4})
+	text = resultText(result)
+	t.Logf("check plus_0_n after Qed:\n%s", text)
+	if result.IsError {
+		t.Fatalf("check at an explicit position failed:\n%s", text)
+	}
+	flat := strings.Join(strings.Fields(text), " ")
+	if !strings.Contains(flat, "plus_0_n") || !strings.Contains(flat, "forall n : nat, 0 + n = n") {
+		t.Errorf("expected plus_0_n : forall n : nat, 0 + n = n, got:\n%s", text)
+	}
+}
+
 func TestQuerySearch(t *testing.T) {
 	sm := NewStateManager(nil)
 	defer sm.Shutdown()
@@ -220,7 +334,7 @@ func TestQuerySearch(t *testing.T) {
 
 	DoCheckAll(sm, path)
 
-	result, _, _ := DoSearch(sm, path, "0 + _ = _")
+	result, _, _ := DoSearch(sm, path, "0 + _ = _", nil, SearchOptions{})
 	text := resultText(result)
 	t.Logf("search result:\n%s", text)
 	if !strings.Contains(text, "plus_0_n") && !strings.Contains(text, "Search Results") {
@@ -394,3 +508,31 @@ complex_goal_flow is defined
 		t.Fatalf("CloseDoc: %v", err)
 	}
 }
+
+func TestDocumentState(t *testing.T) {
+	sm := NewStateManager(nil)
+	defer sm.Shutdown()
+
+	path := testdataPath("error.v")
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatalf("OpenDoc: %v", err)
+	}
+
+	DoCheckAll(sm, path)
+
+	result, _, _ := DoDocumentState(sm, path)
+	text := resultText(result)
+	t.Logf("document state:\n%s", text)
+	if !strings.Contains(text, "=== Sentences:") {
+		t.Errorf("expected sentence summary, got:\n%s", text)
+	}
+	if !strings.Contains(text, "=== Errors ===") {
+		t.Errorf("expected error section, got:\n%s", text)
+	}
+	// Statuses come from the parsed dump, corrected by the diagnostics.
+	for _, want := range []string{"L1–1 [executed] Theorem bad : True.", "L3–3 [error] exact 42."} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q, got:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package rocq
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stuckProver behaves like echoProver, except that while stuck is set it
+// never answers proof commands, and it never answers prover/check requests.
+// It answers prover/search with 300 results, sent ahead of the reply.
+// Cancelled request IDs are sent to cancels.
+type stuckProver struct {
+	codec   *lspCodec
+	stuck   atomic.Bool
+	cancels chan int64
+}
+
+func (p *stuckProver) run() {
+	for {
+		msg, err := p.codec.decode()
+		if err != nil {
+			return
+		}
+		if msg.Method == nil {
+			continue
+		}
+		switch method := *msg.Method; {
+		case method == "$/cancelRequest":
+			var c struct {
+				ID int64 `json:"id"`
+			}
+			json.Unmarshal(msg.Params, &c)
+			p.cancels <- c.ID
+		case method == "prover/check":
+		case method == "prover/search":
+			// More results than a search channel holds, all ahead of the reply.
+			var params struct {
+				ID string `json:"id"`
+			}
+			json.Unmarshal(msg.Params, &params)
+			for i := range 300 {
+				p.codec.encode(map[string]any{"jsonrpc": "2.0", "method": "prover/searchResult",
+					"params": map[string]any{"id": params.ID, "name": fmt.Sprintf("r%d", i), "statement": "True"}})
+			}
+			p.codec.encode(map[string]any{"jsonrpc": "2.0", "id": *msg.ID, "result": nil})
+		case msg.ID != nil:
+			p.codec.encode(map[string]any{"jsonrpc": "2.0", "id": *msg.ID, "result": nil})
+		case strings.HasPrefix(method, "prover/") && !p.stuck.Load():
+			var params struct {
+				TextDocument struct {
+					URI string `json:"uri"`
+				} `json:"textDocument"`
+			}
+			json.Unmarshal(msg.Params, &params)
+			p.codec.encode(map[string]any{"jsonrpc": "2.0", "method": "prover/proofView",
+				"params": map[string]any{"proof": map[string]any{"goals": []any{}}}})
+			p.publish(params.TextDocument.URI, 0, "ran")
+		}
+	}
+}
+
+// publish sends one diagnostic on line (0-based); severity 1 unless message is "ran".
+func (p *stuckProver) publish(uri string, line int, message string) {
+	severity := 1
+	if message == "ran" {
+		severity = 3
+	}
+	pos := map[string]any{"line": line, "character": 0}
+	p.codec.encode(map[string]any{"jsonrpc": "2.0", "method": "textDocument/publishDiagnostics",
+		"params": map[string]any{"uri": uri, "diagnostics": []any{map[string]any{
+			"range": map[string]any{"start": pos, "end": pos}, "severity": severity, "message": message,
+		}}}})
+}
+
+func newStuckStateManager() (*StateManager, *stuckProver) {
+	sm := NewStateManager(nil)
+	prover := &stuckProver{cancels: make(chan int64, 4)}
+	sm.startClient = func(_ []string, tap tapFunc) (*VsrocqClient, error) {
+		toProver, fromClient := io.Pipe()
+		fromProver, toClient := io.Pipe()
+		prover.codec = newLSPCodec(toProver, toClient)
+		go prover.run()
+		return newClientConn(fromProver, fromClient, tap), nil
+	}
+	return sm, prover
+}
+
+// waitFor polls cond, failing the test if it does not hold within a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for range 100 {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestInterruptProofCommand(t *testing.T) {
+	sm, prover := newStuckStateManager()
+	defer sm.Shutdown()
+	path := writeTestFile(t, t.TempDir(), "a.v", "Definition n := 1.\nCompute loop.\nLemma foo : True.\n")
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if res := resultText(toolResult(DoInterrupt(sm, path))); !strings.Contains(res, "Nothing running") {
+		t.Errorf("idle interrupt: %q", res)
+	}
+
+	prover.stuck.Store(true)
+	done := make(chan string)
+	go func() { done <- resultText(toolResult(DoCheckAll(sm, path))) }()
+	waitFor(t, "the check to wait on vsrocq", func() bool {
+		sm.Mu.Lock()
+		defer sm.Mu.Unlock()
+		return sm.Docs[FileURI(path)].interrupt != nil
+	})
+
+	start := time.Now()
+	if res := resultText(toolResult(DoInterrupt(sm, path))); !strings.Contains(res, "running proof command") {
+		t.Errorf("interrupt: %q", res)
+	}
+	// What vsrocq reports once the interrupted sentence fails.
+	prover.publish(FileURI(path), 1, "User interrupt.")
+	select {
+	case res := <-done:
+		if !strings.Contains(res, "Interrupted at line 2.") || !strings.Contains(res, "User interrupt.") {
+			t.Errorf("interrupted check: %q", res)
+		}
+	case <-time.After(NotifyTimeout / 2):
+		t.Fatal("check not unblocked by interrupt")
+	}
+	if d := time.Since(start); d > interruptGrace {
+		t.Errorf("interrupted check took %v", d)
+	}
+
+	// The document is usable again without a reset.
+	prover.stuck.Store(false)
+	if res := resultText(toolResult(DoCheckAll(sm, path))); strings.Contains(res, "Interrupted") || !strings.Contains(res, "ran") {
+		t.Errorf("check after interrupt: %q", res)
+	}
+}
+
+// A failed SIGINT leaves the command waiting and interruptible.
+func TestInterruptSignalFails(t *testing.T) {
+	sm, prover := newStuckStateManager()
+	defer sm.Shutdown()
+	path := writeTestFile(t, t.TempDir(), "a.v", "Compute loop.\n")
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatal(err)
+	}
+	var fail atomic.Bool
+	fail.Store(true)
+	sm.Mu.Lock()
+	sm.Client.signal = func() error {
+		if fail.Load() {
+			return errors.New("process already finished")
+		}
+		return nil
+	}
+	sm.Mu.Unlock()
+
+	prover.stuck.Store(true)
+	done := make(chan string)
+	go func() { done <- resultText(toolResult(DoCheckAll(sm, path))) }()
+	waitFor(t, "the check to wait on vsrocq", func() bool {
+		sm.Mu.Lock()
+		defer sm.Mu.Unlock()
+		return sm.Docs[FileURI(path)].interrupt != nil
+	})
+
+	if res := resultText(toolResult(DoInterrupt(sm, path))); !strings.Contains(res, "interrupt vsrocqtop") {
+		t.Errorf("failed interrupt: %q", res)
+	}
+	sm.Mu.Lock()
+	waiting := sm.Docs[FileURI(path)].interrupt != nil
+	sm.Mu.Unlock()
+	if !waiting {
+		t.Fatal("command no longer interruptible after a failed SIGINT")
+	}
+	fail.Store(false)
+
+	if res := resultText(toolResult(DoInterrupt(sm, path))); !strings.Contains(res, "running proof command") {
+		t.Errorf("interrupt: %q", res)
+	}
+	prover.publish(FileURI(path), 0, "User interrupt.")
+	select {
+	case res := <-done:
+		if !strings.Contains(res, "Interrupted at line 1.") {
+			t.Errorf("interrupted check: %q", res)
+		}
+	case <-time.After(NotifyTimeout / 2):
+		t.Fatal("check not unblocked by interrupt")
+	}
+}
+
+func TestInterruptQuery(t *testing.T) {
+	sm, prover := newStuckStateManager()
+	defer sm.Shutdown()
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "a.v", "Definition n := 1.\n")
+	other := writeTestFile(t, dir, "b.v", "Definition m := 1.\n")
+	for _, p := range []string{path, other} {
+		if err := sm.OpenDoc(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan string)
+	otherDone := make(chan string)
+	go func() { done <- resultText(toolResult(DoQuery(sm, path, "prover/check", "loop", nil))) }()
+	go func() { otherDone <- resultText(toolResult(DoQuery(sm, other, "prover/check", "loop", nil))) }()
+	waitFor(t, "the queries to be sent", func() bool {
+		sm.Client.pendingMu.Lock()
+		defer sm.Client.pendingMu.Unlock()
+		return len(sm.Client.pending) == 2
+	})
+
+	if res := resultText(toolResult(DoInterrupt(sm, path))); !strings.Contains(res, "1 pending request") {
+		t.Errorf("interrupt: %q", res)
+	}
+	select {
+	case res := <-done:
+		if !strings.Contains(res, "interrupted") {
+			t.Errorf("interrupted query: %q", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("query not unblocked by interrupt")
+	}
+	select {
+	case <-prover.cancels:
+	case <-time.After(time.Second):
+		t.Error("vsrocq not sent $/cancelRequest")
+	}
+
+	// The query on the other file is still waiting for vsrocq.
+	select {
+	case res := <-otherDone:
+		t.Errorf("query on another file ended: %q", res)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if res := resultText(toolResult(DoInterrupt(sm, other))); !strings.Contains(res, "1 pending request") {
+		t.Errorf("interrupt of other file: %q", res)
+	}
+	<-otherDone
+}
@@ -0,0 +1,247 @@
+package rocq
+
+// jobs.go — background jobs: long operations that return a job ID at once
+// instead of blocking the tool call. The job's progress and partial
+// diagnostics are polled with rocq_job_status, its result awaited with
+// rocq_job_result, and the job stopped with rocq_job_cancel.
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Job states.
+const (
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobCancelled = "cancelled"
+)
+
+// JobTimeout bounds how long a job waits for vsrocq to finish executing; it
+// replaces NotifyTimeout, which is sized for calls that block the client.
+// Proof commands started meanwhile fail as busy rather than wait (see
+// acquireProver).
+const JobTimeout = time.Hour
+
+// maxFinishedJobs is how many finished jobs are kept for rocq_job_result;
+// older ones are forgotten.
+const maxFinishedJobs = 32
+
+// job is one background operation on a document.
+type job struct {
+	id, kind, file string
+	started        time.Time
+
+	// Guarded by sm.Mu.
+	state     string
+	finished  time.Time
+	result    *mcp.CallToolResult
+	doc       *DocState // set once the job has the document's prover turn
+	cancelled bool
+
+	cancel chan struct{} // closed when cancelled is set
+	done   chan struct{} // closed when the job finishes
+}
+
+// startJob runs fn in the background as a job of the given kind on file and
+// returns the job. fn receives the job so it can tell when it is cancelled.
+func (sm *StateManager) startJob(kind, file string, fn func(j *job) *mcp.CallToolResult) *job {
+	sm.Mu.Lock()
+	sm.jobSeq++
+	j := &job{
+		id:      fmt.Sprintf("job-%d", sm.jobSeq),
+		kind:    kind,
+		file:    file,
+		started: time.Now(),
+		state:   JobRunning,
+		cancel:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	sm.jobs[j.id] = j
+	sm.evictJobsLocked()
+	sm.Mu.Unlock()
+
+	go func() {
+		res := fn(j)
+		sm.Mu.Lock()
+		j.result, j.finished, j.doc = res, time.Now(), nil
+		j.state = JobDone
+		if j.cancelled {
+			j.state = JobCancelled
+		}
+		sm.Mu.Unlock()
+		close(j.done)
+	}()
+	return j
+}
+
+// evictJobsLocked forgets the oldest finished jobs beyond maxFinishedJobs.
+// Caller must hold sm.Mu.
+func (sm *StateManager) evictJobsLocked() {
+	var finished []*job
+	for _, j := range sm.jobs {
+		if j.state != JobRunning {
+			finished = append(finished, j)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	slices.SortFunc(finished, func(a, b *job) int { return a.finished.Compare(b.finished) })
+	for _, j := range finished[:len(finished)-maxFinishedJobs] {
+		delete(sm.jobs, j.id)
+	}
+}
+
+// getJob looks up a job by ID.
+// Caller must hold sm.Mu.
+func (sm *StateManager) getJob(id string) (*job, error) {
+	j, ok := sm.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("no job %q (finished jobs are kept only for the last %d)", id, maxFinishedJobs)
+	}
+	return j, nil
+}
+
+// DoCheckAllAsync starts rocq_check_all as a background job and returns its ID.
+func DoCheckAllAsync(sm *StateManager, file string) (*mcp.CallToolResult, any, error) {
+	j := sm.startJob("check_all", file, func(j *job) *mcp.CallToolResult {
+		doc, release, err := sm.acquireProverUnless(file, j, j.cancel)
+		if errors.Is(err, errCancelledWaiting) {
+			return TextResult("Cancelled before it started.")
+		}
+		if err != nil {
+			return ErrResult(err)
+		}
+		defer release()
+
+		// Registered together with j.doc, so that a cancel either finds the
+		// interrupt or stops the job here.
+		interrupt := make(chan struct{})
+		sm.Mu.Lock()
+		cancelled := j.cancelled
+		if !cancelled {
+			j.doc, doc.interrupt = doc, interrupt
+		}
+		sm.Mu.Unlock()
+		if cancelled {
+			return TextResult("Cancelled before it started.")
+		}
+		res, _, _ := checkAll(sm, doc, interrupt, JobTimeout)
+		return res
+	})
+	return TextResult(fmt.Sprintf("Started %s: check_all %s\nPoll it with rocq_job_status, or wait for it with rocq_job_result.", j.id, file)), nil, nil
+}
+
+// DoJobStatus reports a job's state, elapsed time, progress through its
+// file, and the diagnostics vsrocq has published so far.
+func DoJobStatus(sm *StateManager, id string) (*mcp.CallToolResult, any, error) {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	j, err := sm.getJob(id)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	return TextResult(jobStatus(j)), nil, nil
+}
+
+// jobStatus describes a job for DoJobStatus.
+// Caller must hold sm.Mu.
+func jobStatus(j *job) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %s %s\n", j.id, j.kind, j.file)
+	if j.state != JobRunning {
+		fmt.Fprintf(&sb, "State: %s after %s; fetch the result with rocq_job_result.\n", j.state, j.finished.Sub(j.started).Round(100*time.Millisecond))
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "State: running for %s", time.Since(j.started).Round(100*time.Millisecond))
+	if j.cancelled {
+		sb.WriteString(" (cancelling)")
+	}
+	sb.WriteString("\n")
+
+	doc := j.doc
+	switch {
+	case doc == nil:
+		sb.WriteString("Progress: waiting for its turn on the file\n")
+	default:
+		lines := strings.Count(strings.TrimRight(doc.Content, "\n"), "\n") + 1
+		fmt.Fprintf(&sb, "Progress: checked through line %d of %d\n", doc.processed.Line+1, lines)
+		if len(doc.Diagnostics) > 0 {
+			sb.WriteString("\n")
+			FormatDiagnostics(&sb, doc.Diagnostics)
+		}
+	}
+	return sb.String()
+}
+
+// DoJobResult waits up to wait for a job to finish and returns its result.
+// If it is still running then, its status is returned instead.
+func DoJobResult(sm *StateManager, id string, wait time.Duration) (*mcp.CallToolResult, any, error) {
+	sm.Mu.Lock()
+	j, err := sm.getJob(id)
+	sm.Mu.Unlock()
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-j.done:
+	case <-timer.C:
+		sm.Mu.Lock()
+		defer sm.Mu.Unlock()
+		return TextResult(fmt.Sprintf("Still running after waiting %s.\n\n%s", wait, jobStatus(j))), nil, nil
+	}
+
+	sm.Mu.Lock()
+	header := fmt.Sprintf("%s (%s %s) %s after %s.\n\n", j.id, j.kind, j.file, j.state, j.finished.Sub(j.started).Round(100*time.Millisecond))
+	res := j.result
+	sm.Mu.Unlock()
+
+	out := &mcp.CallToolResult{IsError: res.IsError}
+	out.Content = append([]mcp.Content{&mcp.TextContent{Text: header}}, res.Content...)
+	return out, nil, nil
+}
+
+// DoJobCancel stops a job. A job still waiting for its turn on the file is
+// dropped; one that is checking is interrupted, as by rocq_interrupt, and
+// its result shows where it stopped.
+func DoJobCancel(sm *StateManager, id string) (*mcp.CallToolResult, any, error) {
+	sm.Mu.Lock()
+	j, err := sm.getJob(id)
+	var state string
+	var doc *DocState
+	if err == nil {
+		state, doc = j.state, j.doc
+		if state == JobRunning && !j.cancelled {
+			j.cancelled = true
+			close(j.cancel)
+		}
+	}
+	sm.Mu.Unlock()
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	if state != JobRunning {
+		return TextResult(fmt.Sprintf("%s already %s", id, state)), nil, nil
+	}
+	if doc != nil {
+		if _, err := sm.interruptWaiter(doc); err != nil {
+			return ErrResult(err), nil, nil
+		}
+	}
+
+	select {
+	case <-j.done:
+		return TextResult(fmt.Sprintf("Cancelled %s; rocq_job_result shows where it stopped.", id)), nil, nil
+	case <-time.After(interruptGrace + time.Second):
+		return TextResult(fmt.Sprintf("Cancelling %s; it has not stopped yet.", id)), nil, nil
+	}
+}
@@ -0,0 +1,209 @@
+package rocq
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// startedJob returns the job ID in a DoCheckAllAsync result.
+func startedJob(t *testing.T, res string) string {
+	t.Helper()
+	id := regexp.MustCompile(`job-\d+`).FindString(res)
+	if id == "" {
+		t.Fatalf("no job ID in %q", res)
+	}
+	return id
+}
+
+func TestJobResult(t *testing.T) {
+	sm, _ := newStuckStateManager()
+	defer sm.Shutdown()
+	path := writeTestFile(t, t.TempDir(), "a.v", "Definition n := 1.\n")
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatal(err)
+	}
+
+	id := startedJob(t, resultText(toolResult(DoCheckAllAsync(sm, path))))
+	res := resultText(toolResult(DoJobResult(sm, id, NotifyTimeout)))
+	if !strings.Contains(res, id+" (check_all "+path+") done") || !strings.Contains(res, "ran") {
+		t.Errorf("result: %q", res)
+	}
+	if res := resultText(toolResult(DoJobStatus(sm, id))); !strings.Contains(res, "State: done") {
+		t.Errorf("status of finished job: %q", res)
+	}
+	if res := resultText(toolResult(DoJobCancel(sm, id))); !strings.Contains(res, "already done") {
+		t.Errorf("cancel of finished job: %q", res)
+	}
+	if res := resultText(toolResult(DoJobStatus(sm, "job-99"))); !strings.Contains(res, `no job "job-99"`) {
+		t.Errorf("unknown job: %q", res)
+	}
+}
+
+func TestJobStatusAndCancel(t *testing.T) {
+	sm, prover := newStuckStateManager()
+	defer sm.Shutdown()
+	path := writeTestFile(t, t.TempDir(), "a.v", "Definition n := 1.\nCompute loop.\nLemma foo : True.\n")
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatal(err)
+	}
+
+	prover.stuck.Store(true)
+	id := startedJob(t, resultText(toolResult(DoCheckAllAsync(sm, path))))
+	waitFor(t, "the job to wait on vsrocq", func() bool {
+		sm.Mu.Lock()
+		defer sm.Mu.Unlock()
+		return sm.Docs[FileURI(path)].interrupt != nil
+	})
+
+	// vsrocq reports the first sentence processed and the second processing.
+	pos := map[string]any{"line": 0, "character": 18}
+	prover.codec.encode(map[string]any{"jsonrpc": "2.0", "method": "prover/updateHighlights",
+		"params": map[string]any{"uri": FileURI(path), "processedRange": []any{
+			map[string]any{"start": map[string]any{"line": 0, "character": 0}, "end": pos},
+		}, "processingRange": []any{
+			map[string]any{"start": map[string]any{"line": 1, "character": 0}, "end": map[string]any{"line": 1, "character": 13}},
+		}}})
+	waitFor(t, "the progress report", func() bool {
+		return strings.Contains(resultText(toolResult(DoJobStatus(sm, id))), "checked through line 1 of 3")
+	})
+
+	if res := resultText(toolResult(DoJobResult(sm, id, 10*time.Millisecond))); !strings.Contains(res, "Still running") {
+		t.Errorf("result of running job: %q", res)
+	}
+
+	done := make(chan string)
+	go func() { done <- resultText(toolResult(DoJobCancel(sm, id))) }()
+	waitFor(t, "the job to be interrupted", func() bool {
+		sm.Mu.Lock()
+		defer sm.Mu.Unlock()
+		return sm.Docs[FileURI(path)].interrupt == nil
+	})
+	prover.publish(FileURI(path), 1, "User interrupt.")
+	if res := <-done; !strings.Contains(res, "Cancelled "+id) {
+		t.Errorf("cancel: %q", res)
+	}
+	res := resultText(toolResult(DoJobResult(sm, id, time.Second)))
+	if !strings.Contains(res, "cancelled after") || !strings.Contains(res, "Interrupted at line 2.") {
+		t.Errorf("result of cancelled job: %q", res)
+	}
+}
+
+func TestJobWaitsForExecution(t *testing.T) {
+	sm, prover := newStuckStateManager()
+	defer sm.Shutdown()
+	path := writeTestFile(t, t.TempDir(), "a.v", "Definition n := 1.\nCompute slow.\nLemma foo : True.\n")
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatal(err)
+	}
+	uri := FileURI(path)
+	highlights := func(processing bool) {
+		var pending []any
+		if processing {
+			pending = []any{map[string]any{"start": map[string]any{"line": 1, "character": 0}, "end": map[string]any{"line": 1, "character": 13}}}
+		}
+		prover.codec.encode(map[string]any{"jsonrpc": "2.0", "method": "prover/updateHighlights",
+			"params": map[string]any{"uri": uri, "processingRange": pending, "processedRange": []any{
+				map[string]any{"start": map[string]any{"line": 0, "character": 0}, "end": map[string]any{"line": 0, "character": 18}},
+			}}})
+	}
+
+	prover.stuck.Store(true)
+	id := startedJob(t, resultText(toolResult(DoCheckAllAsync(sm, path))))
+	waitFor(t, "the job to wait on vsrocq", func() bool {
+		sm.Mu.Lock()
+		defer sm.Mu.Unlock()
+		return sm.Docs[uri].interrupt != nil
+	})
+
+	// Partial results while vsrocq is still processing do not end the job.
+	highlights(true)
+	prover.publish(uri, 0, "ran")
+	prover.codec.encode(map[string]any{"jsonrpc": "2.0", "method": "prover/proofView",
+		"params": map[string]any{"proof": map[string]any{"goals": []any{}}}})
+	if res := resultText(toolResult(DoJobResult(sm, id, 3*settleTime))); !strings.Contains(res, "Still running") {
+		t.Fatalf("job ended while vsrocq was processing: %q", res)
+	}
+
+	highlights(false)
+	prover.publish(uri, 2, "Slow failure.")
+	res := resultText(toolResult(DoJobResult(sm, id, time.Second)))
+	if !strings.Contains(res, "done after") || !strings.Contains(res, "Slow failure.") {
+		t.Errorf("result: %q", res)
+	}
+}
+
+// While a job checks one file, proof commands on another fail at once, and a
+// second job waits for its turn until it is cancelled.
+func TestJobHoldsProver(t *testing.T) {
+	sm, prover := newStuckStateManager()
+	defer sm.Shutdown()
+	dir := t.TempDir()
+	a := writeTestFile(t, dir, "a.v", "Compute loop.\n")
+	b := writeTestFile(t, dir, "b.v", "Definition n := 1.\n")
+	for _, path := range []string{a, b} {
+		if err := sm.OpenDoc(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prover.stuck.Store(true)
+	first := startedJob(t, resultText(toolResult(DoCheckAllAsync(sm, a))))
+	waitFor(t, "the job to wait on vsrocq", func() bool {
+		sm.Mu.Lock()
+		defer sm.Mu.Unlock()
+		return sm.Docs[FileURI(a)].interrupt != nil
+	})
+
+	start := time.Now()
+	if res := resultText(toolResult(DoCheckAll(sm, b))); !strings.Contains(res, "busy with "+first) {
+		t.Errorf("check of another file: %q", res)
+	}
+	if d := time.Since(start); d > settleTime {
+		t.Errorf("busy check took %v", d)
+	}
+
+	second := startedJob(t, resultText(toolResult(DoCheckAllAsync(sm, b))))
+	if res := resultText(toolResult(DoJobCancel(sm, second))); !strings.Contains(res, "Cancelled "+second) {
+		t.Errorf("cancel of waiting job: %q", res)
+	}
+	if res := resultText(toolResult(DoJobResult(sm, second, time.Second))); !strings.Contains(res, "Cancelled before it started.") {
+		t.Errorf("result of waiting job: %q", res)
+	}
+
+	go DoJobCancel(sm, first)
+	waitFor(t, "the job to be interrupted", func() bool {
+		sm.Mu.Lock()
+		defer sm.Mu.Unlock()
+		return sm.Docs[FileURI(a)].interrupt == nil
+	})
+	prover.publish(FileURI(a), 0, "User interrupt.")
+	toolResult(DoJobResult(sm, first, time.Second))
+	prover.stuck.Store(false)
+	if res := resultText(toolResult(DoCheckAll(sm, b))); strings.Contains(res, "busy") || !strings.Contains(res, "ran") {
+		t.Errorf("check after the job: %q", res)
+	}
+}
+
+func TestJobEviction(t *testing.T) {
+	sm := NewStateManager(nil)
+	var first *job
+	for i := range maxFinishedJobs + 2 {
+		j := sm.startJob("test", "a.v", func(*job) *mcp.CallToolResult { return TextResult("ok") })
+		<-j.done
+		if i == 0 {
+			first = j
+		}
+	}
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	if len(sm.jobs) > maxFinishedJobs+1 {
+		t.Errorf("%d jobs kept", len(sm.jobs))
+	}
+	if _, err := sm.getJob(first.id); err == nil {
+		t.Error("oldest job not evicted")
+	}
+}
@@ -0,0 +1,139 @@
+package rocq
+
+// lifecycle.go — opt-in document lifecycle: auto-open, idempotent open, and
+// closing idle documents beyond a limit.
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Lifecycle configures how the StateManager opens and closes documents.
+// The zero value requires explicit rocq_open and rocq_close calls.
+type Lifecycle struct {
+	AutoOpen bool // open documents on first use; repeated opens sync instead of failing
+	MaxDocs  int  // if > 0, close the least recently used idle documents beyond this many
+}
+
+// SetLifecycle sets the document lifecycle policy.
+func (sm *StateManager) SetLifecycle(l Lifecycle) {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	sm.lifecycle = l
+}
+
+// Lifecycle returns the document lifecycle policy.
+func (sm *StateManager) Lifecycle() Lifecycle {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	return sm.lifecycle
+}
+
+// Results of EnsureOpen.
+const (
+	OpenNew       = "opened"
+	OpenSynced    = "synced"
+	OpenUnchanged = "unchanged"
+)
+
+// EnsureOpen opens path if it is not open, and otherwise syncs it if the file
+// on disk differs from the content vsrocq has. It reports which it did.
+func (sm *StateManager) EnsureOpen(path string) (string, error) {
+	err := sm.OpenDoc(path)
+	if err == nil {
+		return OpenNew, nil
+	}
+	if !errors.Is(err, ErrAlreadyOpen) {
+		return "", err
+	}
+
+	doc, release, err := sm.lockDoc(path, false)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	disk, err := sm.readFile(path)
+	if err != nil {
+		return "", err
+	}
+	sm.Mu.Lock()
+	same := doc.Content == string(disk)
+	sm.Mu.Unlock()
+	if same {
+		return OpenUnchanged, nil
+	}
+	if err := sm.changeDoc(doc, string(disk)); err != nil {
+		return "", err
+	}
+	return OpenSynced, nil
+}
+
+// evictIdle closes the least recently used documents with no queued or
+// running operations until at most Lifecycle.MaxDocs remain. The document
+// keep is never closed. Caller must not hold sm.Mu.
+func (sm *StateManager) evictIdle(keep string) {
+	type candidate struct {
+		uri      string
+		lastUsed time.Time
+	}
+	sm.Mu.Lock()
+	limit := sm.lifecycle.MaxDocs
+	var idle []candidate
+	for uri, doc := range sm.Docs {
+		if uri != keep && doc.users == 0 {
+			idle = append(idle, candidate{uri, doc.lastUsed})
+		}
+	}
+	excess := len(sm.Docs) - limit
+	sm.Mu.Unlock()
+	if limit <= 0 || excess <= 0 {
+		return
+	}
+
+	slices.SortFunc(idle, func(a, b candidate) int {
+		return a.lastUsed.Compare(b.lastUsed)
+	})
+	for _, c := range idle[:min(excess, len(idle))] {
+		log.Printf("closing idle document %s (limit %d open)", c.uri, limit)
+		sm.Mu.Lock()
+		var names []string
+		if doc := sm.Docs[c.uri]; doc != nil {
+			names = checkpointNames(doc)
+		}
+		sm.Mu.Unlock()
+		if err := sm.CloseDoc(URIPath(c.uri)); err != nil {
+			log.Printf("close idle document: %v", err)
+			continue
+		}
+		if len(names) > 0 {
+			log.Printf("checkpoints of %s lost: %s", c.uri, strings.Join(names, ", "))
+			sm.Mu.Lock()
+			sm.lostCheckpoints[c.uri] = names
+			sm.Mu.Unlock()
+		}
+	}
+}
+
+// LostCheckpoints returns the names of the checkpoints path had when it was
+// last closed to stay within Lifecycle.MaxDocs, other than ones saved again
+// since.
+func (sm *StateManager) LostCheckpoints(path string) []string {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	return sm.lostCheckpoints[FileURI(path)]
+}
+
+// lostCheckpointError explains a missing checkpoint that was lost when its
+// document was closed as idle, or returns nil. Caller must hold sm.Mu.
+func (sm *StateManager) lostCheckpointError(doc *DocState, name string) error {
+	if !slices.Contains(sm.lostCheckpoints[doc.URI], name) {
+		return nil
+	}
+	return fmt.Errorf("checkpoint %q was lost when %s was closed as idle to keep at most %d documents open (--max-docs)",
+		name, URIPath(doc.URI), sm.lifecycle.MaxDocs)
+}
@@ -0,0 +1,112 @@
+package rocq
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAutoOpen(t *testing.T) {
+	sm := newEchoStateManager()
+	defer sm.Shutdown()
+	path := writeTestFile(t, t.TempDir(), "a.v", "Lemma foo : True.\n")
+
+	if res := resultText(toolResult(DoCheckAll(sm, path))); !strings.Contains(res, "document not open") {
+		t.Fatalf("without auto-open: %q", res)
+	}
+
+	sm.SetLifecycle(Lifecycle{AutoOpen: true})
+	if res := resultText(toolResult(DoCheckAll(sm, path))); !strings.Contains(res, "ran "+path+" v1") {
+		t.Errorf("with auto-open: %q", res)
+	}
+}
+
+func TestEnsureOpen(t *testing.T) {
+	sm := newEchoStateManager()
+	defer sm.Shutdown()
+	path := writeTestFile(t, t.TempDir(), "a.v", "Lemma foo : True.\n")
+
+	steps := []struct {
+		content string // written before the call, if non-empty
+		want    string
+	}{
+		{"", OpenNew},
+		{"", OpenUnchanged},
+		{"Lemma bar : True.\n", OpenSynced},
+		{"", OpenUnchanged},
+	}
+	for i, s := range steps {
+		if s.content != "" {
+			writeTestFile(t, filepath.Dir(path), "a.v", s.content)
+		}
+		got, err := sm.EnsureOpen(path)
+		if err != nil || got != s.want {
+			t.Errorf("step %d: EnsureOpen = %q, %v; want %q", i, got, err, s.want)
+		}
+	}
+
+	sm.Mu.Lock()
+	doc, _ := sm.GetDoc(path)
+	version, content := doc.Version, doc.Content
+	sm.Mu.Unlock()
+	if version != 2 || content != "Lemma bar : True.\n" {
+		t.Errorf("version %d, content %q", version, content)
+	}
+}
+
+func TestEvictIdle(t *testing.T) {
+	sm := newEchoStateManager()
+	defer sm.Shutdown()
+	sm.SetLifecycle(Lifecycle{AutoOpen: true, MaxDocs: 2})
+	dir := t.TempDir()
+	a := writeTestFile(t, dir, "a.v", "")
+	b := writeTestFile(t, dir, "b.v", "")
+	c := writeTestFile(t, dir, "c.v", "")
+
+	for _, path := range []string{a, b} {
+		if err := sm.OpenDoc(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if res := toolResult(DoCheckpoint(sm, b, "before")); res.IsError {
+		t.Fatal(resultText(res))
+	}
+	// Using a makes b the least recently used.
+	for _, path := range []string{a, c} {
+		if err := sm.SyncDoc(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sm.Mu.Lock()
+	var open []string
+	for uri := range sm.Docs {
+		open = append(open, filepath.Base(URIPath(uri)))
+	}
+	sm.Mu.Unlock()
+	if len(open) != 2 || strings.Contains(strings.Join(open, ","), "b.v") {
+		t.Errorf("open documents = %v, want a.v and c.v", open)
+	}
+
+	// b is reopened on use, but its checkpoint is gone, and says so.
+	if lost := sm.LostCheckpoints(b); len(lost) != 1 || lost[0] != "before" {
+		t.Errorf("lost checkpoints = %q", lost)
+	}
+	if res := resultText(toolResult(DoRestore(sm, b, "before"))); !strings.Contains(res, "was lost when") {
+		t.Errorf("restore after eviction: %q", res)
+	}
+	toolResult(DoCheckpoint(sm, b, "before"))
+	if lost := sm.LostCheckpoints(b); len(lost) != 0 {
+		t.Errorf("lost checkpoints after saving again = %q", lost)
+	}
+}
@@ -0,0 +1,266 @@
+package rocq
+
+// limits.go — resource limits for vsrocqtop: an address-space limit set
+// before it executes, and a CPU-time ceiling per proof command. A proof command that
+// breaks a limit, or during which vsrocqtop dies, kills and restarts the
+// prover and reports which limit was hit and which sentence was running.
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits bounds the resources vsrocqtop may use. The zero value sets none.
+type Limits struct {
+	Memory int64         // address space of the vsrocqtop process, in bytes
+	CPU    time.Duration // CPU time vsrocqtop may spend on one proof command
+}
+
+// The CPU limit is watched only while a proof command waits on vsrocq (see
+// watchLimits); queries, searches, hovers and completions are not limited,
+// though rocq_interrupt cancels them.
+
+// cpuPollInterval is how often the CPU time of vsrocqtop is sampled.
+const cpuPollInterval = 100 * time.Millisecond
+
+// SetLimits sets the resource limits. The memory limit applies to vsrocqtop
+// processes started afterwards.
+func (sm *StateManager) SetLimits(l Limits) error {
+	if l != (Limits{}) {
+		if err := limitsSupported(); err != nil {
+			return err
+		}
+	}
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	sm.limits = l
+	return nil
+}
+
+// ParseSize parses a byte count with an optional binary suffix: "512M", "8G", "8GiB".
+func ParseSize(s string) (int64, error) {
+	num := strings.TrimRight(strings.ToUpper(strings.TrimSpace(s)), "IB")
+	shift := 0
+	if num != "" {
+		if i := strings.IndexByte("KMGT", num[len(num)-1]); i >= 0 {
+			shift = 10 * (i + 1)
+			num = num[:len(num)-1]
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)>>shift {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n << shift, nil
+}
+
+// formatSize renders a byte count in the largest binary unit that divides it.
+func formatSize(n int64) string {
+	for _, u := range []struct {
+		shift int
+		name  string
+	}{{40, "TiB"}, {30, "GiB"}, {20, "MiB"}, {10, "KiB"}} {
+		if n >= 1<<u.shift && n%(1<<u.shift) == 0 {
+			return fmt.Sprintf("%d %s", n>>u.shift, u.name)
+		}
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// limitBreach reports that vsrocqtop exceeded a limit, or died, while a proof
+// command was waiting on it.
+type limitBreach struct {
+	client  *VsrocqClient // the vsrocqtop that was killed or died
+	limit   string        // the limit exceeded, e.g. "CPU time limit (30s)"; empty if unknown
+	exit    string        // how vsrocqtop exited, if it did on its own
+	running string        // the sentence being executed
+}
+
+func (b *limitBreach) Error() string {
+	switch {
+	case b.limit != "" && b.exit != "":
+		return fmt.Sprintf("%s exceeded while running %s (vsrocqtop %s)", b.limit, b.running, b.exit)
+	case b.limit != "":
+		return fmt.Sprintf("%s exceeded while running %s", b.limit, b.running)
+	}
+	return fmt.Sprintf("vsrocqtop %s while running %s", b.exit, b.running)
+}
+
+// watchLimits enforces the CPU limit, and notices vsrocqtop dying, while a
+// proof command waits on doc. A breach is recorded in doc.breach and
+// interrupts the wait. The returned function stops watching; once it returns,
+// no breach will be recorded.
+func (sm *StateManager) watchLimits(doc *DocState) (stop func()) {
+	sm.Mu.Lock()
+	client, limits := sm.Client, sm.limits
+	sm.Mu.Unlock()
+	if client == nil || client.cmd == nil {
+		return func() {}
+	}
+	pid := client.cmd.Process.Pid
+
+	quit := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		var tick <-chan time.Time
+		var base time.Duration
+		if limits.CPU > 0 {
+			if t, err := processCPUTime(pid); err == nil {
+				base = t
+				ticker := time.NewTicker(cpuPollInterval)
+				defer ticker.Stop()
+				tick = ticker.C
+			}
+		}
+		for {
+			select {
+			case <-quit:
+				return
+			case <-client.exited:
+				b := &limitBreach{client: client, exit: "exited"}
+				if client.waitErr != nil {
+					b.exit = "exited (" + client.waitErr.Error() + ")"
+				}
+				if limits.Memory > 0 && outOfMemory(client.stderr.String()) {
+					b.limit = fmt.Sprintf("memory limit (%s)", formatSize(limits.Memory))
+				}
+				sm.recordBreach(doc, b)
+				return
+			case <-tick:
+				t, err := processCPUTime(pid)
+				if err != nil || t-base <= limits.CPU {
+					continue
+				}
+				client.kill()
+				sm.recordBreach(doc, &limitBreach{client: client, limit: fmt.Sprintf("CPU time limit (%s)", limits.CPU)})
+				return
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-finished
+	}
+}
+
+// outOfMemory reports whether vsrocqtop's last stderr output shows it died
+// for lack of memory, as OCaml reports a failed allocation.
+func outOfMemory(stderr string) bool {
+	s := strings.ToLower(stderr)
+	return strings.Contains(s, "out of memory") || strings.Contains(s, "out_of_memory")
+}
+
+// recordBreach stores a breach on doc, naming the sentence that was running,
+// and interrupts the proof command waiting on it.
+func (sm *StateManager) recordBreach(doc *DocState, b *limitBreach) {
+	sm.Mu.Lock()
+	b.running = runningSentence(doc)
+	doc.breach = b
+	waiting := doc.interrupt
+	doc.interrupt = nil
+	sm.Mu.Unlock()
+	if waiting != nil {
+		close(waiting)
+	}
+}
+
+// runningSentence describes the sentence vsrocq was executing in doc: the
+// first one past the range it last reported as processed.
+// Caller must hold sm.Mu.
+func runningSentence(doc *DocState) string {
+	off := PositionToOffset(doc.Content, doc.processed)
+	for _, s := range splitSentences(doc.Content) {
+		if s.End > off {
+			p := OffsetToPosition(doc.Content, s.Start)
+			text := strings.Join(strings.Fields(stripComments(s.Text)), " ")
+			return fmt.Sprintf("line %d: %s", p.Line+1, truncate(text, 80))
+		}
+	}
+	return "the end of the file"
+}
+
+// recoverFrom restarts vsrocqtop after a breach and returns the error to
+// report for the proof command.
+func (sm *StateManager) recoverFrom(b *limitBreach) error {
+	if err := sm.restartClient(b.client); err != nil {
+		return fmt.Errorf("%w; restarting vsrocqtop failed: %v", b, err)
+	}
+	return fmt.Errorf("%w; vsrocqtop was restarted and open documents reopened, unchecked", b)
+}
+
+// restartClient replaces a vsrocqtop that was killed or died with a fresh
+// one and reopens every open document in it. Documents lose their execution
+// state, as if just opened. Nothing is done if old was already replaced, and
+// nothing is started once the server is shutting down.
+func (sm *StateManager) restartClient(old *VsrocqClient) error {
+	sm.clientMu.Lock()
+	defer sm.clientMu.Unlock()
+	old.kill()
+
+	sm.Mu.Lock()
+	replaced, stopped := sm.Client != old, sm.stopped
+	if !replaced && !stopped {
+		sm.Client = nil
+	}
+	sm.Mu.Unlock()
+	if stopped {
+		return errors.New("server shutting down")
+	}
+	if replaced {
+		return nil
+	}
+	if err := sm.startClientLocked(); err != nil {
+		return err
+	}
+
+	sm.Mu.Lock()
+	client := sm.Client
+	var opens []map[string]any
+	for _, doc := range sm.Docs {
+		doc.ProofView, doc.Diagnostics = nil, nil
+		doc.ExecPos, doc.processed = Position{}, Position{}
+		opens = append(opens, map[string]any{
+			"textDocument": map[string]any{
+				"uri":        doc.URI,
+				"languageId": "rocq",
+				"version":    doc.Version,
+				"text":       doc.Content,
+			},
+		})
+	}
+	sm.Mu.Unlock()
+	for _, params := range opens {
+		if err := client.Notify("textDocument/didOpen", params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tailBuffer is an io.Writer that keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.max; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
@@ -0,0 +1,50 @@
+package rocq
+
+// limits_linux.go — applying and measuring prover resource limits on Linux.
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// clockTicks is the kernel's USER_HZ, the unit of CPU times in /proc.
+const clockTicks = 100
+
+func limitsSupported() error { return nil }
+
+// limitMemory caps the address space of the calling process, and of the
+// programs it executes.
+func limitMemory(bytes int64) error {
+	lim := unix.Rlimit{Cur: uint64(bytes), Max: uint64(bytes)}
+	return unix.Setrlimit(unix.RLIMIT_AS, &lim)
+}
+
+// execProgram replaces the process with path.
+func execProgram(path string, argv []string) error {
+	return unix.Exec(path, argv, os.Environ())
+}
+
+// processCPUTime returns the user plus system CPU time process pid has used.
+func processCPUTime(pid int) (time.Duration, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// Fields after the parenthesized command name, starting with the state (field 3).
+	s := string(data)
+	fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("parse /proc/%d/stat", pid)
+	}
+	utime, err1 := strconv.ParseInt(fields[11], 10, 64)
+	stime, err2 := strconv.ParseInt(fields[12], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("parse /proc/%d/stat", pid)
+	}
+	return time.Duration(utime+stime) * time.Second / clockTicks, nil
+}
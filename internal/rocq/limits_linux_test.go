@@ -0,0 +1,167 @@
+package rocq
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestProverHelper is a stand-in vsrocqtop run as a subprocess by the limit
+// tests. Proof commands on a document containing "loop" report the first
+// sentence processed and then spin; on one containing "alloc" it dies as
+// OCaml does when out of memory. Other documents get an empty proof view and
+// a "ran" diagnostic.
+func TestProverHelper(t *testing.T) {
+	if os.Getenv("ROCQ_PROVER_HELPER") == "" {
+		t.Skip("helper for the prover limit tests")
+	}
+	codec := newLSPCodec(os.Stdin, os.Stdout)
+	texts := map[string]string{}
+	for {
+		msg, err := codec.decode()
+		if err != nil {
+			os.Exit(0)
+		}
+		if msg.Method == nil {
+			continue
+		}
+		if msg.ID != nil {
+			codec.encode(map[string]any{"jsonrpc": "2.0", "id": *msg.ID, "result": nil})
+			continue
+		}
+		var p struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		json.Unmarshal(msg.Params, &p)
+		uri := p.TextDocument.URI
+		switch *msg.Method {
+		case "exit":
+			os.Exit(0)
+		case "textDocument/didOpen":
+			texts[uri] = p.TextDocument.Text
+		case "textDocument/didChange":
+			texts[uri] = p.ContentChanges[0].Text
+		case "prover/interpretToEnd":
+			switch {
+			case strings.Contains(texts[uri], "loop"):
+				end := map[string]any{"line": 0, "character": strings.IndexByte(texts[uri], '.') + 1}
+				codec.encode(map[string]any{"jsonrpc": "2.0", "method": "prover/updateHighlights",
+					"params": map[string]any{"uri": uri, "processedRange": []any{map[string]any{
+						"start": map[string]any{"line": 0, "character": 0}, "end": end}},
+						"processingRange": []any{map[string]any{"start": end, "end": map[string]any{"line": 1, "character": 0}}}}})
+				for {
+				}
+			case strings.Contains(texts[uri], "alloc"):
+				fmt.Fprintln(os.Stderr, "Fatal error: out of memory.")
+				os.Exit(2)
+			}
+			codec.encode(map[string]any{"jsonrpc": "2.0", "method": "prover/proofView",
+				"params": map[string]any{"proof": map[string]any{"goals": []any{}}}})
+			codec.encode(map[string]any{"jsonrpc": "2.0", "method": "textDocument/publishDiagnostics",
+				"params": map[string]any{"uri": uri, "diagnostics": []any{map[string]any{
+					"range":    map[string]any{"start": map[string]any{"line": 0, "character": 0}, "end": map[string]any{"line": 0, "character": 1}},
+					"severity": 3, "message": "ran"}}}})
+		}
+	}
+}
+
+// newHelperStateManager returns a StateManager whose prover is TestProverHelper.
+func newHelperStateManager(t *testing.T) *StateManager {
+	t.Setenv("ROCQ_PROVER_HELPER", "1")
+	sm := NewStateManager(nil)
+	sm.startClient = func(_ []string, tap tapFunc) (*VsrocqClient, error) {
+		return startVsrocq([]string{os.Args[0], "-test.run=^TestProverHelper$"}, tap)
+	}
+	return sm
+}
+
+func TestCPULimit(t *testing.T) {
+	sm := newHelperStateManager(t)
+	defer sm.Shutdown()
+	if err := sm.SetLimits(Limits{CPU: cpuPollInterval * 3}); err != nil {
+		t.Fatal(err)
+	}
+	path := writeTestFile(t, t.TempDir(), "a.v", "Definition a := 1.\nCompute loop.\n")
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatal(err)
+	}
+	first := sm.Client
+
+	res := resultText(toolResult(DoCheckAll(sm, path)))
+	for _, want := range []string{"CPU time limit (300ms) exceeded", "line 2: Compute loop.", "restarted"} {
+		if !strings.Contains(res, want) {
+			t.Errorf("check result %q lacks %q", res, want)
+		}
+	}
+	if sm.Client == first {
+		t.Fatal("prover not restarted")
+	}
+
+	// The document was reopened in the new prover and can be fixed and checked.
+	if err := os.WriteFile(path, []byte("Definition a := 1.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.SyncDoc(path); err != nil {
+		t.Fatal(err)
+	}
+	if res := resultText(toolResult(DoCheckAll(sm, path))); !strings.Contains(res, "ran") {
+		t.Errorf("check after restart: %q", res)
+	}
+}
+
+func TestProverDeathOutOfMemory(t *testing.T) {
+	sm := newHelperStateManager(t)
+	defer sm.Shutdown()
+	// Generous enough for the helper to run, even under the race detector.
+	if err := sm.SetLimits(Limits{Memory: 1 << 50}); err != nil {
+		t.Fatal(err)
+	}
+	path := writeTestFile(t, t.TempDir(), "a.v", "Definition a := alloc.\n")
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatal(err)
+	}
+
+	res := resultText(toolResult(DoCheckAll(sm, path)))
+	for _, want := range []string{"memory limit (1024 TiB) exceeded", "line 1: Definition a := alloc.", "exit status 2", "restarted"} {
+		if !strings.Contains(res, want) {
+			t.Errorf("check result %q lacks %q", res, want)
+		}
+	}
+}
+
+// TestMemoryLimitHelper is run as a subprocess by TestMemoryLimitBeforeExec.
+func TestMemoryLimitHelper(t *testing.T) {
+	if os.Getenv("ROCQ_MEMORY_HELPER") == "" {
+		t.Skip("helper for TestMemoryLimitBeforeExec")
+	}
+	err := RunSandboxed([]string{"--unconfined", "--max-memory", "8589934592", "--", "cat", "/proc/self/limits"})
+	t.Fatal(err)
+}
+
+// The memory limit is in place when the program starts.
+func TestMemoryLimitBeforeExec(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMemoryLimitHelper$")
+	cmd.Env = append(os.Environ(), "ROCQ_MEMORY_HELPER=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("limited cat: %v\n%s", err, out)
+	}
+	for line := range strings.SplitSeq(string(out), "\n") {
+		if strings.HasPrefix(line, "Max address space") {
+			if f := strings.Fields(line); len(f) < 5 || f[3] != "8589934592" || f[4] != "8589934592" {
+				t.Errorf("address space limit: %q", line)
+			}
+			return
+		}
+	}
+	t.Errorf("no address space limit in:\n%s", out)
+}
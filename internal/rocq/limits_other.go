@@ -0,0 +1,18 @@
+//go:build !linux
+
+package rocq
+
+import (
+	"errors"
+	"time"
+)
+
+var errNoLimits = errors.New("prover resource limits require Linux")
+
+func limitsSupported() error { return errNoLimits }
+
+func limitMemory(bytes int64) error { return errNoLimits }
+
+func execProgram(path string, argv []string) error { return errNoLimits }
+
+func processCPUTime(pid int) (time.Duration, error) { return 0, errNoLimits }
@@ -0,0 +1,51 @@
+package rocq
+
+import (
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"1024", 1024},
+		{"512M", 512 << 20},
+		{"8g", 8 << 30},
+		{"8GiB", 8 << 30},
+		{"2T", 2 << 40},
+		{"16KB", 16 << 10},
+	}
+	for _, tt := range tests {
+		if got, err := ParseSize(tt.in); err != nil || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "G", "-1G", "1.5G", "8X", "99999999999T"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q): expected error", in)
+		}
+	}
+	if got := formatSize(8 << 30); got != "8 GiB" {
+		t.Errorf("formatSize = %q", got)
+	}
+}
+
+func TestRunningSentence(t *testing.T) {
+	doc := &DocState{Content: "Definition a := 1.\n(* slow *) Compute\n  big   a.\nLemma b : True.\n"}
+	tests := []struct {
+		processed Position
+		want      string
+	}{
+		{Position{}, "line 1: Definition a := 1."},
+		{Position{Line: 0, Character: 18}, "line 2: Compute big a."},
+		{Position{Line: 2, Character: 10}, "line 4: Lemma b : True."},
+		{Position{Line: 4}, "the end of the file"},
+	}
+	for _, tt := range tests {
+		doc.processed = tt.processed
+		if got := runningSentence(doc); got != tt.want {
+			t.Errorf("processed to %v: runningSentence = %q, want %q", tt.processed, got, tt.want)
+		}
+	}
+}
@@ -19,8 +19,12 @@ type lspCodec struct {
 	writer io.Writer
 	mu     sync.Mutex // protects writer
 	nextID atomic.Int64
+	tap    tapFunc // if non-nil, sees every message body sent or received
 }
 
+// tapFunc observes raw message bodies; dir is "send" or "recv".
+type tapFunc func(dir string, body []byte)
+
 func newLSPCodec(r io.Reader, w io.Writer) *lspCodec {
 	c := &lspCodec{
 		reader: bufio.NewReader(r),
@@ -77,6 +81,9 @@ func (c *lspCodec) encode(msg any) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.tap != nil {
+		c.tap("send", data)
+	}
 	if _, err := io.WriteString(c.writer, header); err != nil {
 		return err
 	}
@@ -115,6 +122,10 @@ func (c *lspCodec) decode() (*rawMessage, error) {
 		return nil, fmt.Errorf("read body: %w", err)
 	}
 
+	if c.tap != nil {
+		c.tap("recv", body)
+	}
+
 	var msg rawMessage
 	if err := json.Unmarshal(body, &msg); err != nil {
 		return nil, fmt.Errorf("unmarshal: %w", err)
@@ -0,0 +1,366 @@
+package rocq
+
+// navigate.go — go-to-definition and hover, via LSP with load-path fallbacks.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxExcerptLines bounds the source excerpt shown for a definition.
+const maxExcerptLines = 15
+
+// definitionSite is a resolved definition: its file, range, and file content.
+type definitionSite struct {
+	Path    string
+	Range   Range
+	Content string
+	Outside bool // outside the allowed roots; Content is not shown
+}
+
+// ParseLocations decodes a textDocument/definition result, which may be null,
+// a Location, a Location[] or a LocationLink[].
+func ParseLocations(raw json.RawMessage) []Location {
+	type rawLoc struct {
+		URI                  string `json:"uri"`
+		Range                Range  `json:"range"`
+		TargetURI            string `json:"targetUri"`
+		TargetSelectionRange *Range `json:"targetSelectionRange"`
+		TargetRange          Range  `json:"targetRange"`
+	}
+	var many []rawLoc
+	if json.Unmarshal(raw, &many) != nil {
+		var one rawLoc
+		if json.Unmarshal(raw, &one) != nil {
+			return nil
+		}
+		many = []rawLoc{one}
+	}
+	var locs []Location
+	for _, l := range many {
+		switch {
+		case l.TargetURI != "" && l.TargetSelectionRange != nil:
+			locs = append(locs, Location{URI: l.TargetURI, Range: *l.TargetSelectionRange})
+		case l.TargetURI != "":
+			locs = append(locs, Location{URI: l.TargetURI, Range: l.TargetRange})
+		case l.URI != "":
+			locs = append(locs, Location{URI: l.URI, Range: l.Range})
+		}
+	}
+	return locs
+}
+
+// RenderHover renders a textDocument/hover result's contents, which may be a
+// string, MarkupContent, MarkedString, or an array of MarkedStrings.
+func RenderHover(raw json.RawMessage) string {
+	var hover struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if json.Unmarshal(raw, &hover) != nil || len(hover.Contents) == 0 {
+		return ""
+	}
+	render := func(c json.RawMessage) string {
+		var s string
+		if json.Unmarshal(c, &s) == nil {
+			return s
+		}
+		var v struct {
+			Value string `json:"value"`
+		}
+		json.Unmarshal(c, &v)
+		return v.Value
+	}
+	var parts []json.RawMessage
+	if json.Unmarshal(hover.Contents, &parts) != nil {
+		return strings.TrimSpace(render(hover.Contents))
+	}
+	var texts []string
+	for _, p := range parts {
+		if t := strings.TrimSpace(render(p)); t != "" {
+			texts = append(texts, t)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '\'' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// identAt returns the (possibly qualified) identifier under pos, or "".
+func identAt(content string, pos Position) string {
+	off := PositionToOffset(content, pos)
+	start, end := off, off
+	for start > 0 && isIdentChar(content[start-1]) {
+		start--
+	}
+	for end < len(content) && isIdentChar(content[end]) {
+		end++
+	}
+	return strings.Trim(content[start:end], ".")
+}
+
+// loadPathEntry maps a logical path prefix to a directory (-Q/-R flags).
+type loadPathEntry struct {
+	Dir     string
+	Logical string
+}
+
+// parseLoadPath extracts -Q/-R mappings from vsrocqtop arguments, accepting both
+// "-Q dir Log" and "-Q dir,Log".
+func parseLoadPath(args []string) []loadPathEntry {
+	var entries []loadPathEntry
+	for i := 0; i < len(args); i++ {
+		if args[i] != "-Q" && args[i] != "-R" || i+1 >= len(args) {
+			continue
+		}
+		if dir, logical, ok := strings.Cut(args[i+1], ","); ok {
+			entries = append(entries, loadPathEntry{Dir: dir, Logical: logical})
+			i++
+		} else if i+2 < len(args) {
+			entries = append(entries, loadPathEntry{Dir: args[i+1], Logical: args[i+2]})
+			i += 2
+		}
+	}
+	return entries
+}
+
+// resolveLibraryFile finds the .v file on the load path that defines a fully
+// qualified name, trying the longest module path first.
+func resolveLibraryFile(entries []loadPathEntry, qualified string) string {
+	parts := strings.Split(qualified, ".")
+	for n := len(parts) - 1; n > 0; n-- {
+		module := strings.Join(parts[:n], ".")
+		for _, e := range entries {
+			rest, ok := strings.CutPrefix(module, e.Logical)
+			if !ok || rest != "" && e.Logical != "" && !strings.HasPrefix(rest, ".") {
+				continue
+			}
+			rel := strings.Split(strings.TrimPrefix(rest, "."), ".")
+			path := filepath.Join(append([]string{e.Dir}, rel...)...) + ".v"
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// locateName extracts the qualified name from Locate output such as
+// "Constant Stdlib.Init.Nat.add".
+func locateName(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return ""
+	}
+	switch fields[0] {
+	case "Constant", "Inductive", "Constructor", "Ltac", "Module", "Projection":
+		return fields[1]
+	}
+	return ""
+}
+
+// findDeclaration returns the offset of the declaration of name in content, or -1.
+func findDeclaration(content, name string) int {
+	for _, m := range declNameRe.FindAllStringSubmatchIndex(content, -1) {
+		if content[m[2]:m[3]] == name {
+			return m[0] + len(content[m[0]:m[1]]) - len(strings.TrimLeft(content[m[0]:m[1]], " \t\r\n"))
+		}
+	}
+	return -1
+}
+
+// docComment returns the (** ... *) comment directly preceding offset, if any.
+func docComment(content string, offset int) string {
+	text := strings.TrimRight(content[:offset], " \t\r\n")
+	if !strings.HasSuffix(text, "*)") {
+		return ""
+	}
+	i := strings.LastIndex(text, "(**")
+	if i < 0 || strings.Contains(text[i:len(text)-2], "*)") {
+		return ""
+	}
+	return strings.Join(strings.Fields(text[i+3:len(text)-2]), " ")
+}
+
+// FormatExcerpt renders the source lines of r with 1-indexed line numbers.
+func FormatExcerpt(content string, r Range) string {
+	lines := strings.Split(content, "\n")
+	start := min(r.Start.Line, len(lines)-1)
+	end := min(max(r.End.Line, start), start+maxExcerptLines-1, len(lines)-1)
+	var sb strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&sb, "%5d | %s\n", i+1, lines[i])
+	}
+	return sb.String()
+}
+
+// findDefinitions resolves the definition of the identifier at pos: first via
+// textDocument/definition, then by locating the name and searching the -Q/-R
+// load path for its source. The string result describes the identifier when
+// no source could be found.
+func findDefinitions(sm *StateManager, doc *DocState, content string, pos Position) ([]definitionSite, string, error) {
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": doc.URI},
+		"position":     map[string]any{"line": pos.Line, "character": pos.Character},
+	}
+	if result, err := sm.Client.Request("textDocument/definition", params); err == nil {
+		var sites []definitionSite
+		for _, loc := range ParseLocations(result) {
+			text, err := sm.documentText(loc.URI)
+			if errors.Is(err, ErrOutsideRoots) {
+				sites = append(sites, definitionSite{Path: URIPath(loc.URI), Range: loc.Range, Outside: true})
+				continue
+			}
+			if err != nil {
+				continue
+			}
+			sites = append(sites, definitionSite{Path: URIPath(loc.URI), Range: loc.Range, Content: text})
+		}
+		if len(sites) > 0 {
+			return sites, "", nil
+		}
+	}
+
+	ident := identAt(content, pos)
+	if ident == "" {
+		return nil, "", fmt.Errorf("no identifier at line %d:%d", pos.Line+1, pos.Character)
+	}
+	params["pattern"] = ident
+	result, err := sm.Client.Request("prover/locate", params)
+	if err != nil {
+		return nil, "", err
+	}
+	located := RenderPpcmd(json.RawMessage(result))
+	qualified := locateName(located)
+	if qualified == "" {
+		return nil, located, nil
+	}
+	path := resolveLibraryFile(parseLoadPath(sm.args), qualified)
+	if path == "" {
+		return nil, located, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, located, nil
+	}
+	text := string(data)
+	short := qualified[strings.LastIndex(qualified, ".")+1:]
+	off := findDeclaration(text, short)
+	if off < 0 {
+		return nil, located + "\nSource file: " + path, nil
+	}
+	p := OffsetToPosition(text, off)
+	return []definitionSite{{Path: path, Range: Range{Start: p, End: p}, Content: text}}, "", nil
+}
+
+// documentText returns the text of uri: the open document's content if it is
+// open, otherwise the file on disk if it is allowed.
+func (sm *StateManager) documentText(uri string) (string, error) {
+	sm.Mu.Lock()
+	doc, ok := sm.lookupURI(uri)
+	var content string
+	if ok {
+		content = doc.Content
+	}
+	sm.Mu.Unlock()
+	if ok {
+		return content, nil
+	}
+	data, err := sm.readFile(URIPath(uri))
+	return string(data), err
+}
+
+// DoDefinition returns the defining file, range and source excerpt of the
+// identifier at the given position.
+func DoDefinition(sm *StateManager, file string, line, col int) (*mcp.CallToolResult, any, error) {
+	doc, release, err := sm.acquireDoc(file)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	defer release()
+
+	sm.Mu.Lock()
+	content := doc.Content
+	sm.Mu.Unlock()
+
+	sites, note, err := findDefinitions(sm, doc, content, Position{Line: line, Character: col})
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	if len(sites) == 0 {
+		if note == "" {
+			note = "No definition found."
+		}
+		return TextResult(note), nil, nil
+	}
+
+	var sb strings.Builder
+	for i, site := range sites {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "=== Definition: %s:%d:%d ===\n", site.Path, site.Range.Start.Line+1, site.Range.Start.Character)
+		if site.Outside {
+			sb.WriteString("(source not shown: outside the allowed roots)\n")
+			continue
+		}
+		sb.WriteString(FormatExcerpt(site.Content, site.Range))
+	}
+	return TextResult(sb.String()), nil, nil
+}
+
+// DoHover returns the type and doc comment of the identifier at the given
+// position, from textDocument/hover or, failing that, from Check.
+func DoHover(sm *StateManager, file string, line, col int) (*mcp.CallToolResult, any, error) {
+	doc, release, err := sm.acquireDoc(file)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	defer release()
+
+	sm.Mu.Lock()
+	content := doc.Content
+	sm.Mu.Unlock()
+
+	pos := Position{Line: line, Character: col}
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": doc.URI},
+		"position":     map[string]any{"line": line, "character": col},
+	}
+	var text string
+	if result, err := sm.Client.Request("textDocument/hover", params); err == nil {
+		text = RenderHover(result)
+	}
+	if text == "" {
+		ident := identAt(content, pos)
+		if ident == "" {
+			return TextResult("No identifier at this position."), nil, nil
+		}
+		params["pattern"] = ident
+		result, err := sm.Client.Request("prover/check", params)
+		if err != nil {
+			return ErrResult(err), nil, nil
+		}
+		text = RenderPpcmd(json.RawMessage(result))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(text)
+	sb.WriteString("\n")
+	if sites, _, err := findDefinitions(sm, doc, content, pos); err == nil && len(sites) > 0 {
+		site := sites[0]
+		lineStart := PositionToOffset(site.Content, Position{Line: site.Range.Start.Line})
+		if doc := docComment(site.Content, lineStart); doc != "" {
+			fmt.Fprintf(&sb, "\n%s\n", doc)
+		}
+	}
+	return TextResult(sb.String()), nil, nil
+}
@@ -0,0 +1,138 @@
+package rocq
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseLocations(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []Location
+	}{
+		{"null", `null`, nil},
+		{"location", `{"uri":"file:///a.v","range":{"start":{"line":1,"character":2},"end":{"line":1,"character":5}}}`,
+			[]Location{{URI: "file:///a.v", Range: Range{Start: Position{1, 2}, End: Position{1, 5}}}}},
+		{"links", `[{"targetUri":"file:///b.v","targetRange":{"start":{"line":3,"character":0},"end":{"line":9,"character":0}},"targetSelectionRange":{"start":{"line":3,"character":6},"end":{"line":3,"character":9}}}]`,
+			[]Location{{URI: "file:///b.v", Range: Range{Start: Position{3, 6}, End: Position{3, 9}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseLocations(json.RawMessage(tt.raw))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("location %d: got %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRenderHover(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{`{"contents":{"kind":"markdown","value":"nat -> nat"}}`, "nat -> nat"},
+		{`{"contents":"plain"}`, "plain"},
+		{`{"contents":[{"language":"coq","value":"x : nat"},"doc"]}`, "x : nat\n\ndoc"},
+		{`null`, ""},
+	}
+	for _, tt := range tests {
+		if got := RenderHover(json.RawMessage(tt.raw)); got != tt.want {
+			t.Errorf("RenderHover(%s) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestIdentAt(t *testing.T) {
+	content := "Check Nat.add_comm.\nexact (H' x)."
+	tests := []struct {
+		pos  Position
+		want string
+	}{
+		{Position{0, 8}, "Nat.add_comm"},
+		{Position{0, 18}, "Nat.add_comm"},
+		{Position{1, 8}, "H'"},
+		{Position{1, 5}, "exact"},
+		{Position{1, 6}, ""},
+	}
+	for _, tt := range tests {
+		if got := identAt(content, tt.pos); got != tt.want {
+			t.Errorf("identAt(%v) = %q, want %q", tt.pos, got, tt.want)
+		}
+	}
+}
+
+func TestResolveLibraryFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "sub", "Lib.v")
+	if err := os.WriteFile(path, []byte("(** Doubles n. *)\nDefinition double n := n + n.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := parseLoadPath([]string{"-w", "-notation", "-Q", dir, "Proj", "-R", "/nonexistent,Other"})
+	if len(entries) != 2 || entries[0].Logical != "Proj" || entries[1].Dir != "/nonexistent" {
+		t.Fatalf("parseLoadPath: got %+v", entries)
+	}
+	if got := resolveLibraryFile(entries, "Proj.sub.Lib.double"); got != path {
+		t.Errorf("resolveLibraryFile = %q, want %q", got, path)
+	}
+	if got := resolveLibraryFile(entries, "Other.Lib.double"); got != "" {
+		t.Errorf("expected no file, got %q", got)
+	}
+
+	data, _ := os.ReadFile(path)
+	off := findDeclaration(string(data), "double")
+	if off != 18 {
+		t.Fatalf("findDeclaration = %d, want 18", off)
+	}
+	if got := docComment(string(data), off); got != "Doubles n." {
+		t.Errorf("docComment = %q", got)
+	}
+}
+
+func TestLocateName(t *testing.T) {
+	if got := locateName("Constant Stdlib.Init.Nat.add\n  (shorter name to refer to it in current context is Nat.add)"); got != "Stdlib.Init.Nat.add" {
+		t.Errorf("got %q", got)
+	}
+	if got := locateName("Notation \"x + y\" := (Nat.add x y)"); got != "" {
+		t.Errorf("notations have no source name, got %q", got)
+	}
+}
+
+func TestFormatExcerpt(t *testing.T) {
+	content := "a\nb\nc\nd\n"
+	got := FormatExcerpt(content, Range{Start: Position{1, 0}, End: Position{2, 1}})
+	want := "    2 | b\n    3 | c\n"
+	if got != want {
+		t.Errorf("mismatch.\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestDocumentTextAllowedRoots(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	inside := writeTestFile(t, filepath.Join(dir, "src"), "a.v", "Definition a := 1.\n")
+	outside := writeTestFile(t, dir, "secret.v", "Definition s := 1.\n")
+	sm := NewStateManager(nil)
+	sm.SetAllowedRoots([]string{filepath.Join(dir, "src")})
+
+	if text, err := sm.documentText(FileURI(inside)); err != nil || text != "Definition a := 1.\n" {
+		t.Errorf("inside: %q, %v", text, err)
+	}
+	if text, err := sm.documentText(FileURI(outside)); !errors.Is(err, ErrOutsideRoots) || text != "" {
+		t.Errorf("outside: %q, %v", text, err)
+	}
+}
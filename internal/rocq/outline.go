@@ -0,0 +1,392 @@
+package rocq
+
+// outline.go — text-level sentence splitting and structural outlines of .v files.
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// sentence is one Rocq sentence found by splitSentences.
+type sentence struct {
+	Text  string // source text, comments included
+	Start int    // byte offset of the first non-blank, non-comment character
+	End   int    // byte offset just past the terminating '.'
+}
+
+// splitSentences splits Rocq source into sentences without running the prover.
+// A sentence ends at a '.' followed by whitespace or end of input; comments
+// (which nest) and string literals are skipped. Bullets and braces form
+// sentences of their own. Trailing text without a final '.' is returned as a
+// last, unterminated sentence.
+func splitSentences(content string) []sentence {
+	var out []sentence
+	n := len(content)
+	start := -1
+	emit := func(end int) {
+		out = append(out, sentence{Text: content[start:end], Start: start, End: end})
+		start = -1
+	}
+	for i := 0; i < n; {
+		c := content[i]
+		if c == '(' && i+1 < n && content[i+1] == '*' {
+			i = skipComment(content, i)
+			continue
+		}
+		if isBlank(c) {
+			i++
+			continue
+		}
+		if start < 0 {
+			start = i
+			if c == '{' && !strings.HasPrefix(content[i:], "{|") || c == '}' {
+				i++
+				emit(i)
+				continue
+			}
+			if c == '-' || c == '+' || c == '*' {
+				for i < n && content[i] == c {
+					i++
+				}
+				emit(i)
+				continue
+			}
+		}
+		switch {
+		case c == '"':
+			i = skipString(content, i)
+		case c == '.' && (i+1 == n || isBlank(content[i+1])):
+			i++
+			emit(i)
+		default:
+			i++
+		}
+	}
+	if start >= 0 {
+		end := len(strings.TrimRight(content, " \t\r\n"))
+		if end > start {
+			out = append(out, sentence{Text: content[start:end], Start: start, End: end})
+		}
+	}
+	return out
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// skipComment returns the offset just past the (possibly nested) comment at i.
+func skipComment(content string, i int) int {
+	depth := 0
+	for i < len(content) {
+		switch {
+		case strings.HasPrefix(content[i:], "(*"):
+			depth++
+			i += 2
+		case strings.HasPrefix(content[i:], "*)"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		case content[i] == '"':
+			i = skipString(content, i)
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+// skipString returns the offset just past the string literal at i ("" escapes a quote).
+func skipString(content string, i int) int {
+	for i++; i < len(content); i++ {
+		if content[i] == '"' {
+			if i+1 < len(content) && content[i+1] == '"' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return i
+}
+
+// stripComments removes comments from a sentence and collapses whitespace.
+func stripComments(text string) string {
+	var sb strings.Builder
+	for i := 0; i < len(text); {
+		switch {
+		case strings.HasPrefix(text[i:], "(*"):
+			i = skipComment(text, i)
+			sb.WriteByte(' ')
+		case text[i] == '"':
+			j := skipString(text, i)
+			sb.WriteString(text[i:j])
+			i = j
+		default:
+			sb.WriteByte(text[i])
+			i++
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// OutlineEntry is one node of a file's structural outline.
+type OutlineEntry struct {
+	Kind     string // vernacular keyword, e.g. "Section", "Lemma", "Require"
+	Name     string
+	Range    Range
+	Status   string // proofs only: "proved", "admitted", "aborted", "open" or "failed"
+	Steps    int    // proofs only: number of sentences in the proof body
+	Children []*OutlineEntry
+}
+
+var (
+	outlinePrefixRe = regexp.MustCompile(`^(?:#\[[^\]]*\]\s*)*(?:(?:Local|Global|Polymorphic|Monomorphic|Program|Private|Cumulative|NonCumulative)\s+)*`)
+	outlineIdentRe  = regexp.MustCompile(`^[A-Za-z_][\w'.]*`)
+)
+
+var theoremKinds = map[string]bool{
+	"Theorem": true, "Lemma": true, "Fact": true, "Remark": true, "Corollary": true,
+	"Proposition": true, "Property": true, "Example": true, "Goal": true,
+}
+
+// declKinds are declarations that may open a proof when given without ":=".
+var declKinds = map[string]bool{
+	"Definition": true, "Fixpoint": true, "CoFixpoint": true, "Instance": true, "Let": true,
+}
+
+var leafKinds = map[string]bool{
+	"Inductive": true, "CoInductive": true, "Variant": true, "Record": true, "Structure": true,
+	"Class": true, "Axiom": true, "Axioms": true, "Parameter": true, "Parameters": true,
+	"Conjecture": true, "Variable": true, "Variables": true, "Hypothesis": true, "Hypotheses": true,
+	"Context": true, "Ltac": true, "Canonical": true, "Coercion": true, "Existing": true,
+	"Notation": true, "Infix": true, "Reserved": true, "Hint": true, "Tactic": true,
+}
+
+var proofEnders = map[string]string{
+	"Qed": "proved", "Defined": "proved", "Save": "proved", "Admitted": "admitted", "Abort": "aborted",
+}
+
+// sentenceHead strips attributes and modifiers and returns the first word and
+// the rest of a comment-free sentence, without the final '.'.
+func sentenceHead(text string) (kw, rest string) {
+	text = strings.TrimSuffix(stripComments(text), ".")
+	text = outlinePrefixRe.ReplaceAllString(text, "")
+	kw, rest, _ = strings.Cut(text, " ")
+	return kw, strings.TrimSpace(rest)
+}
+
+// startsTopLevel reports whether a sentence keyword begins a new top-level item,
+// which implicitly ends an unterminated proof before it.
+func startsTopLevel(kw string) bool {
+	switch kw {
+	case "Section", "Module", "End", "Require", "From", "Import", "Export":
+		return true
+	}
+	return theoremKinds[kw] || declKinds[kw] || leafKinds[kw]
+}
+
+// BuildOutline parses the structure of a .v file from its text alone: sections
+// and modules with their nesting, declarations, Require/Import lines, and
+// proofs with their textual status.
+func BuildOutline(content string) []*OutlineEntry {
+	sentences := splitSentences(content)
+	rangeOf := func(start, end int) Range {
+		return Range{Start: OffsetToPosition(content, start), End: OffsetToPosition(content, end)}
+	}
+
+	var roots []*OutlineEntry
+	var stack []*OutlineEntry
+	add := func(e *OutlineEntry) {
+		if len(stack) > 0 {
+			top := stack[len(stack)-1]
+			top.Children = append(top.Children, e)
+		} else {
+			roots = append(roots, e)
+		}
+	}
+
+	for i := 0; i < len(sentences); i++ {
+		s := sentences[i]
+		kw, rest := sentenceHead(s.Text)
+		switch {
+		case kw == "Section" || kw == "Module":
+			e := &OutlineEntry{Kind: kw, Name: outlineName(kw, rest), Range: rangeOf(s.Start, s.End)}
+			add(e)
+			// "Module M := N." is a complete definition, not an opening.
+			if !strings.Contains(rest, ":=") {
+				stack = append(stack, e)
+			}
+
+		case kw == "End":
+			if len(stack) > 0 {
+				top := stack[len(stack)-1]
+				top.Range.End = OffsetToPosition(content, s.End)
+				stack = stack[:len(stack)-1]
+			}
+
+		case kw == "Require" || kw == "From" || kw == "Import" || kw == "Export":
+			kind := "Import"
+			if kw == "Require" || kw == "From" {
+				kind = "Require"
+			}
+			add(&OutlineEntry{Kind: kind, Name: kw + " " + rest, Range: rangeOf(s.Start, s.End)})
+
+		case theoremKinds[kw] || declKinds[kw] && !strings.Contains(rest, ":="):
+			e := &OutlineEntry{Kind: kw, Name: outlineName(kw, rest), Status: "open"}
+			end := s.End
+			j := i + 1
+			for ; j < len(sentences); j++ {
+				bkw, _ := sentenceHead(sentences[j].Text)
+				if status, ok := proofEnders[bkw]; ok {
+					e.Status = status
+					end = sentences[j].End
+					break
+				}
+				if startsTopLevel(bkw) {
+					j--
+					break
+				}
+				end = sentences[j].End
+				if bkw != "Proof" {
+					e.Steps++
+				}
+			}
+			e.Range = rangeOf(s.Start, end)
+			add(e)
+			i = min(j, len(sentences)-1)
+
+		case declKinds[kw] || leafKinds[kw]:
+			add(&OutlineEntry{Kind: kw, Name: outlineName(kw, rest), Range: rangeOf(s.Start, s.End)})
+		}
+	}
+	return roots
+}
+
+// outlineName extracts a display name from the text following a keyword.
+func outlineName(kw, rest string) string {
+	switch kw {
+	case "Module":
+		for _, w := range []string{"Type ", "Import ", "Export "} {
+			rest = strings.TrimPrefix(rest, w)
+		}
+	case "Notation", "Infix", "Reserved":
+		rest = strings.TrimPrefix(rest, "Notation ")
+		if strings.HasPrefix(rest, `"`) {
+			return rest[:skipString(rest, 0)]
+		}
+	case "Hint", "Tactic", "Existing", "Context", "Goal":
+		return truncate(rest, 60)
+	}
+	if m := outlineIdentRe.FindString(rest); m != "" {
+		return m
+	}
+	return truncate(rest, 60)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "…"
+}
+
+// markFailedProofs sets the status of proofs containing an error diagnostic to "failed".
+func markFailedProofs(entries []*OutlineEntry, diags []Diagnostic) {
+	for _, e := range entries {
+		markFailedProofs(e.Children, diags)
+		if e.Status == "" {
+			continue
+		}
+		if slices.ContainsFunc(diags, func(d Diagnostic) bool {
+			return d.Severity == 1 && d.Range.Start.Line >= e.Range.Start.Line && d.Range.Start.Line <= e.Range.End.Line
+		}) {
+			e.Status = "failed"
+		}
+	}
+}
+
+// FormatOutline renders an outline as an indented tree with 1-indexed line ranges.
+func FormatOutline(entries []*OutlineEntry) string {
+	var sb strings.Builder
+	var count func([]*OutlineEntry) int
+	count = func(es []*OutlineEntry) int {
+		n := len(es)
+		for _, e := range es {
+			n += count(e.Children)
+		}
+		return n
+	}
+	fmt.Fprintf(&sb, "=== Outline: %d entries ===\n", count(entries))
+
+	var write func([]*OutlineEntry, int)
+	write = func(es []*OutlineEntry, depth int) {
+		for _, e := range es {
+			sb.WriteString(strings.Repeat("  ", depth))
+			if e.Range.Start.Line == e.Range.End.Line {
+				fmt.Fprintf(&sb, "L%d ", e.Range.Start.Line+1)
+			} else {
+				fmt.Fprintf(&sb, "L%d–%d ", e.Range.Start.Line+1, e.Range.End.Line+1)
+			}
+			switch e.Kind {
+			case "Require", "Import":
+				sb.WriteString(e.Name)
+			default:
+				fmt.Fprintf(&sb, "%s %s", e.Kind, e.Name)
+			}
+			if e.Status != "" {
+				steps := "steps"
+				if e.Steps == 1 {
+					steps = "step"
+				}
+				fmt.Fprintf(&sb, " [%s, %d %s]", e.Status, e.Steps, steps)
+			}
+			sb.WriteString("\n")
+			write(e.Children, depth+1)
+		}
+	}
+	write(entries, 0)
+	return sb.String()
+}
+
+// DoOutline returns the structural outline of a document. It works from
+// the document text, so it does not require the file to have been executed;
+// proofs with known errors are reported as failed. A file that is not open is
+// read from disk, without opening it.
+func DoOutline(sm *StateManager, file string) (*mcp.CallToolResult, any, error) {
+	sm.Mu.Lock()
+	_, err := sm.GetDoc(file)
+	sm.Mu.Unlock()
+
+	var content string
+	var diags []Diagnostic
+	if err != nil {
+		data, err := sm.readFile(file)
+		if err != nil {
+			return ErrResult(err), nil, nil
+		}
+		content = string(data)
+	} else {
+		doc, release, err := sm.acquireDoc(file)
+		if err != nil {
+			return ErrResult(err), nil, nil
+		}
+		sm.Mu.Lock()
+		content, diags = doc.Content, doc.Diagnostics
+		sm.Mu.Unlock()
+		release()
+	}
+
+	entries := BuildOutline(content)
+	if len(entries) == 0 {
+		return TextResult("No outline entries found in " + file), nil, nil
+	}
+	markFailedProofs(entries, diags)
+	return TextResult(FormatOutline(entries)), nil, nil
+}
@@ -0,0 +1,118 @@
+package rocq
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitSentences(t *testing.T) {
+	content := `(* header. with dots. *)
+Require Import Nat.
+Definition s := "a. ""b"". c".
+Lemma foo : Nat.add 0 1 = 1.
+Proof.
+  (* nested (* comment. *) here. *)
+  - simpl. { reflexivity. }
+Qed.
+Check foo`
+	var got []string
+	for _, s := range splitSentences(content) {
+		got = append(got, s.Text)
+	}
+	want := []string{
+		"Require Import Nat.",
+		`Definition s := "a. ""b"". c".`,
+		"Lemma foo : Nat.add 0 1 = 1.",
+		"Proof.",
+		"-",
+		"simpl.",
+		"{",
+		"reflexivity.",
+		"}",
+		"Qed.",
+		"Check foo",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d sentences %q, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sentence %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBuildOutline(t *testing.T) {
+	content := `From Stdlib Require Import Arith.
+
+Section Foo.
+  Variable n : nat.
+
+  Lemma bar : n = n.
+  Proof.
+    reflexivity.
+  Qed.
+
+  Definition double := n + n.
+End Foo.
+
+Module M := Nat.
+
+#[local] Notation "x ++ y" := (x + y).
+
+Theorem t : True.
+Proof. admit. Admitted.
+
+Lemma unfinished : False.
+Proof.
+  intros.
+`
+	got := FormatOutline(BuildOutline(content))
+	want := `=== Outline: 9 entries ===
+L1 From Stdlib Require Import Arith
+L3–12 Section Foo
+  L4 Variable n
+  L6–9 Lemma bar [proved, 1 step]
+  L11 Definition double
+L14 Module M
+L16 Notation "x ++ y"
+L18–19 Theorem t [admitted, 1 step]
+L21–23 Lemma unfinished [open, 1 step]
+`
+	if got != want {
+		t.Errorf("mismatch.\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestMarkFailedProofs(t *testing.T) {
+	content := "Theorem bad : True.\nProof.\n  exact 42.\nQed.\n"
+	entries := BuildOutline(content)
+	markFailedProofs(entries, []Diagnostic{{
+		Severity: 1,
+		Message:  "type error",
+		Range:    Range{Start: Position{Line: 2, Character: 2}, End: Position{Line: 2, Character: 11}},
+	}})
+	if len(entries) != 1 || entries[0].Status != "failed" {
+		t.Errorf("expected one failed proof, got %s", FormatOutline(entries))
+	}
+}
+
+func TestOutlineUnopenedFile(t *testing.T) {
+	sm := NewStateManager(nil)
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "a.v", "Lemma foo : True.\nProof. exact I. Qed.\n")
+
+	res := resultText(toolResult(DoOutline(sm, path)))
+	if !strings.Contains(res, "foo") {
+		t.Errorf("outline of an unopened file: %q", res)
+	}
+	if len(sm.Docs) != 0 || sm.Client != nil {
+		t.Error("outline opened the file")
+	}
+
+	sm.SetAllowedRoots([]string{filepath.Join(dir, "sub")})
+	if res := toolResult(DoOutline(sm, path)); !res.IsError || !strings.Contains(resultText(res), "outside the allowed roots") {
+		t.Errorf("outline outside the allowed roots: %q", resultText(res))
+	}
+}
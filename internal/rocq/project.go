@@ -0,0 +1,269 @@
+package rocq
+
+// project.go — reading _RocqProject files: the load paths and flags to pass
+// to vsrocqtop, the listed .v files, and their order by Require dependencies.
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProjectFileNames are the names a project file is looked up under in a
+// directory, in order of preference.
+var ProjectFileNames = []string{"_RocqProject", "_CoqProject"}
+
+// Project is a parsed _RocqProject file.
+type Project struct {
+	Path      string     // the project file
+	Args      []string   // vsrocqtop flags: -Q, -R and -I with absolute paths, and -arg values
+	LoadPaths []LoadPath // from -Q and -R, in order
+	Files     []string   // absolute paths of the listed .v files, in order
+}
+
+// LoadPath maps a directory to a logical path prefix, as -Q and -R do.
+type LoadPath struct {
+	Dir     string // absolute
+	Logical string // e.g. "Foo.Bar"; empty for the root
+}
+
+// projectOptionArity gives the number of arguments of each option a project
+// file may contain. Options without a meaning for checking are skipped.
+var projectOptionArity = map[string]int{
+	"-Q": 2, "-R": 2, "-I": 1, "-arg": 1,
+	"-docroot": 1, "-native-compiler": 1, "-generate-meta-for-package": 1,
+	"-extra": 3, "-extra-phony": 3,
+}
+
+// ReadProject reads a project file, or the project file in a directory.
+// Relative paths in it are taken relative to its directory.
+func ReadProject(path string) (*Project, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		for _, name := range ProjectFileNames {
+			if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+				path = filepath.Join(path, name)
+				break
+			}
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read project: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("read project: %w", err)
+	}
+	p, err := parseProject(string(data), filepath.Dir(abs))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p.Path = abs
+	return p, nil
+}
+
+// parseProject parses the contents of a project file in dir.
+func parseProject(content, dir string) (*Project, error) {
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return filepath.Clean(path)
+		}
+		return filepath.Join(dir, path)
+	}
+
+	p := &Project{}
+	tokens := projectTokens(content)
+	for len(tokens) > 0 {
+		tok := tokens[0]
+		if !strings.HasPrefix(tok, "-") {
+			if strings.HasSuffix(tok, ".v") {
+				p.Files = append(p.Files, resolve(tok))
+			}
+			tokens = tokens[1:]
+			continue
+		}
+		n, ok := projectOptionArity[tok]
+		if !ok {
+			return nil, fmt.Errorf("unsupported option %s", tok)
+		}
+		if len(tokens) <= n {
+			return nil, fmt.Errorf("%s requires %d argument(s)", tok, n)
+		}
+		args := tokens[1 : n+1]
+		tokens = tokens[n+1:]
+		switch tok {
+		case "-Q", "-R":
+			d := resolve(args[0])
+			p.LoadPaths = append(p.LoadPaths, LoadPath{Dir: d, Logical: args[1]})
+			p.Args = append(p.Args, tok, d, args[1])
+		case "-I":
+			p.Args = append(p.Args, tok, resolve(args[0]))
+		case "-arg":
+			p.Args = append(p.Args, strings.Fields(args[0])...)
+		}
+	}
+	return p, nil
+}
+
+// projectTokens splits a project file into words. '#' starts a comment to
+// the end of the line; single or double quotes group words.
+func projectTokens(content string) []string {
+	var tokens []string
+	var cur strings.Builder
+	inWord := false
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+			inWord = false
+		}
+	}
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '#' && !inWord:
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+		case isBlank(c):
+			flush()
+		case c == '\'' || c == '"':
+			inWord = true
+			end := strings.IndexByte(content[i+1:], c)
+			if end < 0 {
+				end = len(content) - i - 1
+			}
+			cur.WriteString(content[i+1 : i+1+end])
+			i += end + 1
+		default:
+			inWord = true
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// LogicalName returns the logical name of a .v file under the project's load
+// paths, e.g. "Foo.Bar.Baz" for theories/Bar/Baz.v under -Q theories Foo, or
+// "" if it lies under none.
+func (p *Project) LogicalName(file string) string {
+	best, name := -1, ""
+	for _, lp := range p.LoadPaths {
+		rel, err := filepath.Rel(lp.Dir, file)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		// The longest matching directory wins.
+		if len(lp.Dir) <= best {
+			continue
+		}
+		parts := strings.Split(strings.TrimSuffix(filepath.ToSlash(rel), ".v"), "/")
+		if lp.Logical != "" {
+			parts = append([]string{lp.Logical}, parts...)
+		}
+		best, name = len(lp.Dir), strings.Join(parts, ".")
+	}
+	return name
+}
+
+// DependencyOrder returns the project's files ordered so that each comes
+// after the files it Requires, and otherwise in listed order. Requires of
+// libraries outside the project are ignored; files in a cycle keep their
+// listed order.
+func (p *Project) DependencyOrder() ([]string, error) {
+	names := make(map[string]string, len(p.Files)) // logical name → file
+	for _, f := range p.Files {
+		if n := p.LogicalName(f); n != "" {
+			names[n] = f
+		}
+	}
+
+	deps := make(map[string][]string, len(p.Files))
+	for _, f := range p.Files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+		for _, lib := range requiredLibraries(string(data)) {
+			if dep := resolveLibrary(names, lib); dep != "" && dep != f {
+				deps[f] = append(deps[f], dep)
+			}
+		}
+	}
+
+	var order []string
+	state := map[string]int{} // 1: visiting, 2: done
+	var visit func(f string)
+	visit = func(f string) {
+		if state[f] != 0 {
+			return
+		}
+		state[f] = 1
+		for _, d := range deps[f] {
+			visit(d)
+		}
+		state[f] = 2
+		order = append(order, f)
+	}
+	for _, f := range p.Files {
+		visit(f)
+	}
+	return order, nil
+}
+
+// requiredLibraries returns the libraries named by Require commands in content.
+func requiredLibraries(content string) []libraryRef {
+	var libs []libraryRef
+	for _, s := range splitSentences(content) {
+		kw, rest := sentenceHead(s.Text)
+		from := ""
+		if kw == "From" {
+			from, rest, _ = strings.Cut(rest, " ")
+			kw, rest, _ = strings.Cut(strings.TrimSpace(rest), " ")
+		}
+		if kw != "Require" {
+			continue
+		}
+		for w := range strings.FieldsSeq(rest) {
+			if w == "Import" || w == "Export" || strings.HasPrefix(w, "(") {
+				continue
+			}
+			w, _, _ = strings.Cut(w, "(")
+			libs = append(libs, libraryRef{From: from, Name: w})
+		}
+	}
+	return libs
+}
+
+// libraryRef is a library named in a Require, with its From prefix if any.
+type libraryRef struct {
+	From, Name string
+}
+
+// resolveLibrary finds the project file a Require names: the one whose
+// logical name starts with the From prefix and ends with the name. Of several,
+// the shortest logical name wins.
+func resolveLibrary(names map[string]string, lib libraryRef) string {
+	best := ""
+	for logical := range names {
+		rest := logical
+		if lib.From != "" {
+			var ok bool
+			if rest, ok = strings.CutPrefix(logical, lib.From+"."); !ok {
+				continue
+			}
+		}
+		if rest != lib.Name && !strings.HasSuffix(rest, "."+lib.Name) {
+			continue
+		}
+		if best == "" || len(logical) < len(best) || len(logical) == len(best) && logical < best {
+			best = logical
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return names[best]
+}
@@ -0,0 +1,76 @@
+package rocq
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestReadProject(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "_RocqProject", `# load paths
+-Q theories Foo
+-R "src dir" Bar
+-arg '-w -notation-overridden'
+-I plugin
+theories/B.v
+theories/A.v   # A is listed after B but B requires it
+theories/Sub/C.v
+"src dir/D.v"
+plugin/g_foo.mlg
+`)
+	for _, sub := range []string{"theories/Sub", "src dir"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestFile(t, filepath.Join(dir, "theories"), "A.v", "Definition a := 1.\n")
+	writeTestFile(t, filepath.Join(dir, "theories"), "B.v", "From Foo Require Import A.\nRequire Import Stdlib.Arith.\n")
+	writeTestFile(t, filepath.Join(dir, "theories", "Sub"), "C.v", "Require Import Foo.B Bar.D.\n")
+	writeTestFile(t, filepath.Join(dir, "src dir"), "D.v", "(* Require Import Foo.B. *)\n")
+
+	p, err := ReadProject(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantArgs := []string{"-Q", filepath.Join(dir, "theories"), "Foo", "-R", filepath.Join(dir, "src dir"), "Bar",
+		"-w", "-notation-overridden", "-I", filepath.Join(dir, "plugin")}
+	if !slices.Equal(p.Args, wantArgs) {
+		t.Errorf("Args = %q, want %q", p.Args, wantArgs)
+	}
+	a, b := filepath.Join(dir, "theories", "A.v"), filepath.Join(dir, "theories", "B.v")
+	c, d := filepath.Join(dir, "theories", "Sub", "C.v"), filepath.Join(dir, "src dir", "D.v")
+	if !slices.Equal(p.Files, []string{b, a, c, d}) {
+		t.Errorf("Files = %q", p.Files)
+	}
+	if n := p.LogicalName(c); n != "Foo.Sub.C" {
+		t.Errorf("LogicalName(C) = %q", n)
+	}
+	if n := p.LogicalName(filepath.Join(dir, "elsewhere.v")); n != "" {
+		t.Errorf("LogicalName outside load paths = %q", n)
+	}
+
+	order, err := p.DependencyOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{a, b, d, c}; !slices.Equal(order, want) {
+		t.Errorf("DependencyOrder = %q, want %q", order, want)
+	}
+}
+
+func TestReadProjectErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := ReadProject(dir); err == nil {
+		t.Error("expected error for a directory without a project file")
+	}
+	writeTestFile(t, dir, "_CoqProject", "-Q theories\n")
+	if _, err := ReadProject(dir); err == nil {
+		t.Error("expected error for -Q without a logical path")
+	}
+	writeTestFile(t, dir, "_CoqProject", "-bogus A.v\n")
+	if _, err := ReadProject(dir); err == nil {
+		t.Error("expected error for an unknown option")
+	}
+}
@@ -0,0 +1,121 @@
+package rocq
+
+// prompts.go — workflow prompt text filled in with live proof state.
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// resultString returns the text of a tool result, or an error if it failed.
+func resultString(r *mcp.CallToolResult) (string, error) {
+	text := ResultText(r)
+	if r.IsError {
+		return "", fmt.Errorf("%s", text)
+	}
+	return text, nil
+}
+
+// firstError returns the earliest error diagnostic, or nil if there is none.
+func firstError(diags []Diagnostic) *Diagnostic {
+	var errs []Diagnostic
+	for _, d := range diags {
+		if d.Severity == 1 {
+			errs = append(errs, d)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	first := slices.MinFunc(errs, func(a, b Diagnostic) int {
+		return cmp.Or(
+			cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+			cmp.Compare(a.Range.Start.Character, b.Range.Start.Character),
+		)
+	})
+	return &first
+}
+
+// The prompts below use the tools' handlers, so like the tools they open the
+// file on first use only with Lifecycle.AutoOpen.
+
+// FixFirstErrorPrompt checks the whole file and describes its first error with
+// the surrounding source and the goals just before it.
+func FixFirstErrorPrompt(sm *StateManager, file string) (string, error) {
+	if _, err := resultString(toolResult(DoCheckAll(sm, file))); err != nil {
+		return "", err
+	}
+
+	sm.Mu.Lock()
+	doc, err := sm.GetDoc(file)
+	if err != nil {
+		sm.Mu.Unlock()
+		return "", err
+	}
+	content := doc.Content
+	diag := firstError(doc.Diagnostics)
+	sm.Mu.Unlock()
+
+	if diag == nil {
+		return fmt.Sprintf("%s checks without errors; there is nothing to fix.", file), nil
+	}
+
+	goals, err := resultString(toolResult(DoCheck(sm, file, diag.Range.Start.Line, diag.Range.Start.Character)))
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Fix the first error in %s.\n\n", file)
+	fmt.Fprintf(&sb, "Error at line %d:%d: %s\n\n", diag.Range.Start.Line+1, diag.Range.Start.Character, diag.Message)
+	excerpt := diag.Range
+	excerpt.Start.Line = max(excerpt.Start.Line-3, 0)
+	excerpt.End.Line += 3
+	fmt.Fprintf(&sb, "Source:\n%s\n", FormatExcerpt(content, excerpt))
+	fmt.Fprintf(&sb, "Proof state just before the error:\n%s\n\n", goals)
+	sb.WriteString("Edit the file to fix this error, call rocq_sync, then rocq_check_all to confirm " +
+		"it is gone. Use rocq_check_type, rocq_search and rocq_complete to verify names and types " +
+		"instead of guessing.")
+	return sb.String(), nil
+}
+
+// ProveLemmaPrompt describes a lemma's current proof: its tactics and the
+// goals remaining after them.
+func ProveLemmaPrompt(sm *StateManager, file, name string) (string, error) {
+	state, err := resultString(toolResult(DoCheckProof(sm, file, name, "end", 0)))
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Prove %s in %s.\n\n", name, file)
+	fmt.Fprintf(&sb, "Current proof and remaining goals:\n%s\n\n", state)
+	sb.WriteString("Work one step at a time: add a tactic, call rocq_sync, then " +
+		"rocq_check_proof with position 'end' to see the new goals. Replace Admitted with " +
+		"Qed once no goals remain, and finish with rocq_check_all.")
+	return sb.String(), nil
+}
+
+// ExplainGoalPrompt asks for an explanation of the proof state at a position.
+func ExplainGoalPrompt(sm *StateManager, file string, line, col int) (string, error) {
+	state, err := resultString(toolResult(DoCheck(sm, file, line, col)))
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Explain the proof state in %s at line %d:%d.\n\n", file, line+1, col)
+	fmt.Fprintf(&sb, "%s\n\n", state)
+	sb.WriteString("Say in plain terms what each hypothesis means, what remains to be shown, " +
+		"and which tactics are likely to make progress.")
+	return sb.String(), nil
+}
+
+// toolResult drops the unused structured output of a tool handler's result.
+func toolResult(r *mcp.CallToolResult, _ any, _ error) *mcp.CallToolResult {
+	return r
+}
@@ -0,0 +1,40 @@
+package rocq
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFirstError(t *testing.T) {
+	diag := func(sev, line, char int) Diagnostic {
+		return Diagnostic{Severity: sev, Range: Range{Start: Position{Line: line, Character: char}}}
+	}
+	if got := firstError([]Diagnostic{diag(2, 0, 0)}); got != nil {
+		t.Errorf("warnings only: got %+v, want nil", got)
+	}
+	got := firstError([]Diagnostic{diag(1, 7, 0), diag(2, 1, 0), diag(1, 3, 5), diag(1, 3, 2)})
+	if got == nil || got.Range.Start != (Position{Line: 3, Character: 2}) {
+		t.Errorf("got %+v, want error at 3:2", got)
+	}
+}
+
+func TestPromptsNeedOpenDocument(t *testing.T) {
+	sm := newEchoStateManager()
+	defer sm.Shutdown()
+	path := writeTestFile(t, t.TempDir(), "a.v", "Lemma foo : True.\n")
+
+	if _, err := ExplainGoalPrompt(sm, path, 0, 0); err == nil || !strings.Contains(err.Error(), "document not open") {
+		t.Errorf("prompt on a closed document: err = %v", err)
+	}
+	sm.Mu.Lock()
+	opened := len(sm.Docs)
+	sm.Mu.Unlock()
+	if opened != 0 {
+		t.Fatal("prompt opened the document without auto-open")
+	}
+
+	sm.SetLifecycle(Lifecycle{AutoOpen: true})
+	if _, err := ExplainGoalPrompt(sm, path, 0, 0); err != nil {
+		t.Errorf("prompt with auto-open: %v", err)
+	}
+}
@@ -13,203 +13,430 @@ import (
 
 const NotifyTimeout = 10 * time.Second
 
+// interruptGrace is how long a proof command waits, once interrupted, for
+// vsrocq to report where it stopped.
+const interruptGrace = 2 * time.Second
+
 // DoCheck sends interpretToPoint and waits for proofView + diagnostics.
 func DoCheck(sm *StateManager, file string, line, col int) (*mcp.CallToolResult, any, error) {
-	sm.Mu.Lock()
-	doc, err := sm.GetDoc(file)
+	doc, release, err := sm.acquireProver(file)
 	if err != nil {
-		sm.Mu.Unlock()
 		return ErrResult(err), nil, nil
 	}
+	defer release()
+	return checkAt(sm, doc, Position{Line: line, Character: col})
+}
+
+// checkAt sends interpretToPoint for pos and waits for the results.
+// Caller must hold the document's prover turn (see acquireProver).
+func checkAt(sm *StateManager, doc *DocState, pos Position) (*mcp.CallToolResult, any, error) {
+	sm.Mu.Lock()
 	// Drain channels before sending.
 	DrainChannels(doc)
+	doc.ExecPos = pos
+	sm.active = doc.URI
+	version := doc.Version
 	sm.Mu.Unlock()
 
 	params := map[string]any{
-		"textDocument": map[string]any{"uri": doc.URI, "version": doc.Version},
-		"position":     map[string]any{"line": line, "character": col},
+		"textDocument": map[string]any{"uri": doc.URI, "version": version},
+		"position":     map[string]any{"line": pos.Line, "character": pos.Character},
 	}
 	if err := sm.Client.Notify("prover/interpretToPoint", params); err != nil {
 		return ErrResult(err), nil, nil
 	}
 
-	return collectResultsFull(doc)
+	return collectResultsFull(sm, doc)
 }
 
 // DoCheckAll sends interpretToEnd and waits for results.
 func DoCheckAll(sm *StateManager, file string) (*mcp.CallToolResult, any, error) {
-	sm.Mu.Lock()
-	doc, err := sm.GetDoc(file)
+	doc, release, err := sm.acquireProver(file)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	defer release()
+	return checkAll(sm, doc, sm.registerInterrupt(doc), NotifyTimeout)
+}
+
+// CheckToEnd checks file to the end for batch use, waiting up to timeout for
+// vsrocq to finish, and returns the diagnostics. Unlike DoCheckAll, a check
+// that did not complete is an error: one that timed out, was interrupted,
+// broke a resource limit, or ended without vsrocq publishing diagnostics.
+func CheckToEnd(sm *StateManager, file string, timeout time.Duration) ([]Diagnostic, error) {
+	doc, release, err := sm.acquireProver(file)
 	if err != nil {
-		sm.Mu.Unlock()
+		return nil, err
+	}
+	defer release()
+	interrupt := sm.registerInterrupt(doc)
+	if err := sm.sendToEnd(doc); err != nil {
+		return nil, err
+	}
+	ex, err := sm.awaitExecution(doc, interrupt, timeout)
+	switch {
+	case err != nil:
+		return nil, err
+	case ex.interrupted:
+		return nil, fmt.Errorf("interrupted at line %d", ex.stopped.Line+1)
+	case !ex.finished:
+		return nil, fmt.Errorf("timed out after %s with vsrocq still executing (checked through line %d)", timeout, ex.processed.Line+1)
+	case ex.diags == nil:
+		return nil, fmt.Errorf("vsrocq finished without publishing diagnostics")
+	}
+	return ex.diags, nil
+}
+
+// checkAll sends interpretToEnd and waits up to timeout for the results.
+// Caller must hold the document's prover turn and have registered interrupt.
+func checkAll(sm *StateManager, doc *DocState, interrupt chan struct{}, timeout time.Duration) (*mcp.CallToolResult, any, error) {
+	if err := sm.sendToEnd(doc); err != nil {
 		return ErrResult(err), nil, nil
 	}
+	return collectResults(sm, doc, interrupt, timeout)
+}
+
+// sendToEnd sends interpretToEnd for doc.
+// Caller must hold the document's prover turn.
+func (sm *StateManager) sendToEnd(doc *DocState) error {
+	sm.Mu.Lock()
 	DrainChannels(doc)
+	doc.ExecPos = OffsetToPosition(doc.Content, len(doc.Content))
+	sm.active = doc.URI
+	version := doc.Version
 	sm.Mu.Unlock()
 
 	params := map[string]any{
-		"textDocument": map[string]any{"uri": doc.URI, "version": doc.Version},
-	}
-	if err := sm.Client.Notify("prover/interpretToEnd", params); err != nil {
-		return ErrResult(err), nil, nil
+		"textDocument": map[string]any{"uri": doc.URI, "version": version},
 	}
-
-	return collectResultsFull(doc)
+	return sm.Client.Notify("prover/interpretToEnd", params)
 }
 
 // DoStep sends stepForward or stepBackward and waits for results.
 func DoStep(sm *StateManager, file string, method string) (*mcp.CallToolResult, any, error) {
-	sm.Mu.Lock()
-	doc, err := sm.GetDoc(file)
+	doc, release, err := sm.acquireProver(file)
 	if err != nil {
-		sm.Mu.Unlock()
 		return ErrResult(err), nil, nil
 	}
+	defer release()
+
+	sm.Mu.Lock()
 	DrainChannels(doc)
+	sm.active = doc.URI
+	version := doc.Version
 	sm.Mu.Unlock()
 
 	params := map[string]any{
-		"textDocument": map[string]any{"uri": doc.URI, "version": doc.Version},
+		"textDocument": map[string]any{"uri": doc.URI, "version": version},
 	}
 	if err := sm.Client.Notify(method, params); err != nil {
 		return ErrResult(err), nil, nil
 	}
 
-	return collectResultsFull(doc)
+	return collectResultsFull(sm, doc)
 }
 
 // WaitNotifications waits for proofView and diagnostics notifications from vsrocq.
 func WaitNotifications(doc *DocState) (*ProofView, []Diagnostic) {
-	var pv *ProofView
-	var diags []Diagnostic
-
-	timer := time.NewTimer(NotifyTimeout)
-	defer timer.Stop()
-
-	gotProofView := false
-	gotDiags := false
+	pv, diags, _, _ := waitNotifications(doc, nil, NotifyTimeout)
+	return pv, diags
+}
 
-	for !gotProofView || !gotDiags {
+// settleTime is how long to wait for the rest of vsrocq's notifications once
+// it has finished executing.
+const settleTime = 500 * time.Millisecond
+
+// waitNotifications is WaitNotifications waiting up to timeout in all, and
+// stopped early when interrupt is closed: it then waits only interruptGrace
+// for the notifications vsrocq sends once it has stopped.
+//
+// It waits until vsrocq has finished executing: while its updateHighlights
+// show sentences prepared or processing, the diagnostics it publishes are
+// partial. Once they show none, the proofView and diagnostics that follow
+// are waited for, for settleTime after the last notification. finished
+// reports whether that happened before timeout. (Without updateHighlights,
+// as from older vsrocq, the first proofView and diagnostics are taken.)
+func waitNotifications(doc *DocState, interrupt <-chan struct{}, timeout time.Duration) (pv *ProofView, diags []Diagnostic, interrupted, finished bool) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	settle := time.NewTimer(settleTime)
+	settle.Stop()
+	defer settle.Stop()
+
+	// got*: received since vsrocq last reported nothing pending.
+	gotProofView, gotDiags, pending, idle := false, false, false, false
+	for {
 		select {
 		case pv = <-doc.ProofViewCh:
 			gotProofView = true
 		case diags = <-doc.DiagnosticCh:
 			gotDiags = true
-		case <-timer.C:
-			return pv, diags
-		}
-		// After getting the first notification, give a short window for the second.
-		if !timer.Stop() {
-			select {
-			case <-timer.C:
-			default:
+		case pending = <-doc.HighlightCh:
+			idle = !pending
+			if idle {
+				gotProofView, gotDiags = false, false
 			}
+		case <-interrupt:
+			interrupted = true
+			interrupt = nil
+			deadline.Reset(interruptGrace)
+			continue
+		case <-settle.C:
+			return pv, diags, interrupted, true
+		case <-deadline.C:
+			return pv, diags, interrupted, false
+		}
+		switch {
+		case pending:
+			settle.Stop()
+		case gotProofView && gotDiags:
+			return pv, diags, interrupted, true
+		case gotProofView || gotDiags || idle:
+			settle.Reset(settleTime)
 		}
-		timer.Reset(500 * time.Millisecond)
 	}
-	return pv, diags
 }
 
-// collectResultsFull waits for notifications and formats the complete proof state.
-func collectResultsFull(doc *DocState) (*mcp.CallToolResult, any, error) {
-	pv, diags := WaitNotifications(doc)
-	result := FormatFullResults(pv, diags)
-	if pv != nil {
-		doc.ProofView = pv
+// collectResultsFull waits for notifications and formats the complete proof
+// state. If the wait is interrupted (see DoInterrupt), the result is an error
+// naming the line where execution stopped; if vsrocqtop breaks a resource
+// limit or dies, it is restarted and the result says why (see watchLimits).
+func collectResultsFull(sm *StateManager, doc *DocState) (*mcp.CallToolResult, any, error) {
+	return collectResults(sm, doc, sm.registerInterrupt(doc), NotifyTimeout)
+}
+
+// registerInterrupt makes doc's proof command interruptible through the
+// returned channel (see DoInterrupt).
+func (sm *StateManager) registerInterrupt(doc *DocState) chan struct{} {
+	interrupt := make(chan struct{})
+	sm.Mu.Lock()
+	doc.interrupt = interrupt
+	sm.Mu.Unlock()
+	return interrupt
+}
+
+// collectResults is collectResultsFull with the interrupt channel already
+// registered and the wait for vsrocq bounded by timeout.
+func collectResults(sm *StateManager, doc *DocState, interrupt chan struct{}, timeout time.Duration) (*mcp.CallToolResult, any, error) {
+	ex, err := sm.awaitExecution(doc, interrupt, timeout)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+
+	result := FormatFullResults(ex.pv, ex.diags)
+	header := ""
+	switch {
+	case ex.interrupted:
+		header = fmt.Sprintf("Interrupted at line %d.\n", ex.stopped.Line+1)
+	case !ex.finished:
+		header = fmt.Sprintf("Timed out after %s with vsrocq still executing (checked through line %d); the results so far:\n",
+			timeout, ex.processed.Line+1)
 	}
-	if diags != nil {
-		doc.Diagnostics = diags
+	if header != "" {
+		result.Content = append([]mcp.Content{&mcp.TextContent{Text: header}}, result.Content...)
+		result.IsError = true
 	}
 	return result, nil, nil
 }
 
-// DrainChannels drains all pending notifications from a document's channels.
-func DrainChannels(doc *DocState) {
-	for {
-		select {
-		case <-doc.ProofViewCh:
-		case <-doc.DiagnosticCh:
-		case <-doc.CursorCh:
-		default:
-			return
+// execution is what a proof command got from vsrocq (see awaitExecution).
+type execution struct {
+	pv          *ProofView
+	diags       []Diagnostic
+	interrupted bool
+	finished    bool     // vsrocq finished executing before the timeout
+	stopped     Position // where execution stopped, if interrupted
+	processed   Position // end of what vsrocq reported executed
+}
+
+// awaitExecution waits for the results of the proof command just sent on doc
+// and records them in doc. It fails if vsrocqtop broke a resource limit or
+// died, and was restarted (see watchLimits).
+func (sm *StateManager) awaitExecution(doc *DocState, interrupt chan struct{}, timeout time.Duration) (execution, error) {
+	var ex execution
+	stop := sm.watchLimits(doc)
+	ex.pv, ex.diags, ex.interrupted, ex.finished = waitNotifications(doc, interrupt, timeout)
+	stop()
+
+	sm.Mu.Lock()
+	doc.interrupt = nil
+	breach := doc.breach
+	doc.breach = nil
+	sm.Mu.Unlock()
+	if breach != nil {
+		return ex, sm.recoverFrom(breach)
+	}
+
+	sm.Mu.Lock()
+	if ex.pv != nil {
+		doc.ProofView = ex.pv
+	}
+	if ex.diags != nil {
+		doc.Diagnostics = ex.diags
+	}
+	if ex.interrupted {
+		doc.ExecPos = interruptedAt(doc.ExecPos, ex.diags)
+	}
+	ex.stopped, ex.processed = doc.ExecPos, doc.processed
+	sm.Mu.Unlock()
+	return ex, nil
+}
+
+// interruptedAt returns where an interrupted command stopped: the start of the
+// last sentence vsrocq reported an error for, which is the one the interrupt
+// cut short, or else execPos.
+func interruptedAt(execPos Position, diags []Diagnostic) Position {
+	found := false
+	var at Position
+	for _, d := range diags {
+		if d.Severity != 1 {
+			continue
+		}
+		s := d.Range.Start
+		if !found || s.Line > at.Line || s.Line == at.Line && s.Character > at.Character {
+			at, found = s, true
 		}
 	}
+	if !found {
+		return execPos
+	}
+	return at
 }
 
-// DoQuery sends a query request (about/check/locate/print) and returns the rendered result.
-func DoQuery(sm *StateManager, file string, method string, pattern string) (*mcp.CallToolResult, any, error) {
+// DoInterrupt stops what vsrocq is running for a document: the execution of a
+// proof command, by sending SIGINT to vsrocqtop, and the document's requests
+// awaiting a reply, such as a long query, via $/cancelRequest. Requests about
+// other documents are left alone. It does not wait for the document's turn,
+// which the interrupted operation holds; that operation returns once vsrocq
+// stops.
+func DoInterrupt(sm *StateManager, file string) (*mcp.CallToolResult, any, error) {
 	sm.Mu.Lock()
 	doc, err := sm.GetDoc(file)
+	client := sm.Client
 	sm.Mu.Unlock()
 	if err != nil {
 		return ErrResult(err), nil, nil
 	}
 
-	params := map[string]any{
-		"textDocument": map[string]any{"uri": doc.URI, "version": doc.Version},
-		"position":     map[string]any{"line": 0, "character": 0},
-		"pattern":      pattern,
-	}
-	result, err := sm.Client.Request(method, params)
+	var stopped []string
+	ok, err := sm.interruptWaiter(doc)
 	if err != nil {
 		return ErrResult(err), nil, nil
 	}
-
-	text := RenderPpcmd(json.RawMessage(result))
-	if text == "" {
-		text = "No result."
+	if ok {
+		stopped = append(stopped, "the running proof command")
 	}
-	return TextResult(text), nil, nil
+	if client != nil {
+		if n := client.cancelPending(doc.URI); n > 0 {
+			stopped = append(stopped, fmt.Sprintf("%d pending request(s)", n))
+		}
+	}
+	if len(stopped) == 0 {
+		return TextResult("Nothing running for " + file), nil, nil
+	}
+	return TextResult("Interrupted " + strings.Join(stopped, " and ")), nil, nil
 }
 
-// DoSearch sends a search request and collects results from prover/searchResult notifications.
-func DoSearch(sm *StateManager, file string, pattern string) (*mcp.CallToolResult, any, error) {
+// interruptWaiter stops the proof command waiting on doc, if there is one,
+// by sending SIGINT to vsrocqtop and closing its interrupt channel.
+func (sm *StateManager) interruptWaiter(doc *DocState) (bool, error) {
 	sm.Mu.Lock()
-	doc, err := sm.GetDoc(file)
+	waiting, busy := doc.interrupt, doc.interrupting
+	if waiting != nil && !busy {
+		doc.interrupting = true
+	}
+	client := sm.Client
+	sm.Mu.Unlock()
+	if waiting == nil {
+		return false, nil
+	}
+	if busy {
+		return true, nil // another call is signalling it
+	}
+
+	// The command stays interruptible until the signal has been sent.
+	err := client.interrupt()
+	sm.Mu.Lock()
+	doc.interrupting = false
+	mine := err == nil && doc.interrupt == waiting
+	if mine {
+		doc.interrupt = nil
+	}
 	sm.Mu.Unlock()
 	if err != nil {
-		return ErrResult(err), nil, nil
+		return false, fmt.Errorf("interrupt vsrocqtop: %w", err)
+	}
+	if mine {
+		close(waiting)
 	}
+	return true, nil
+}
 
-	// Register a channel to collect search results before sending the request.
-	searchID := fmt.Sprintf("search-%d", time.Now().UnixNano())
-	resultCh := make(chan SearchResult, 256)
-	sm.RegisterSearchHandler(searchID, resultCh)
-	defer sm.UnregisterSearchHandler(searchID)
+// DrainChannels drains all pending notifications from a document's channels.
+func DrainChannels(doc *DocState) {
+	for {
+		select {
+		case <-doc.ProofViewCh:
+		case <-doc.DiagnosticCh:
+		case <-doc.CursorCh:
+		case <-doc.HighlightCh:
+		default:
+			return
+		}
+	}
+}
 
-	params := map[string]any{
-		"textDocument": map[string]any{"uri": doc.URI, "version": doc.Version},
-		"position":     map[string]any{"line": 0, "character": 0},
-		"pattern":      pattern,
-		"id":           searchID,
+// queryPosition returns the position whose proof context a query runs in:
+// pos if given, otherwise the document's current execution point.
+// Caller must hold sm.Mu.
+func queryPosition(doc *DocState, pos *Position) Position {
+	if pos != nil {
+		return *pos
 	}
-	_, err = sm.Client.Request("prover/search", params)
+	return doc.ExecPos
+}
+
+// DoQuery sends a query request (about/check/locate/print) and returns the rendered result.
+// Names resolve in the proof context at pos (nil means the current execution point),
+// so hypotheses of the focused goal and earlier definitions in the file are visible.
+func DoQuery(sm *StateManager, file string, method string, pattern string, pos *Position) (*mcp.CallToolResult, any, error) {
+	doc, release, err := sm.acquireDoc(file)
 	if err != nil {
 		return ErrResult(err), nil, nil
 	}
+	defer release()
 
-	results := CollectSearchResults(resultCh)
+	sm.Mu.Lock()
+	at := queryPosition(doc, pos)
+	version := doc.Version
+	sm.Mu.Unlock()
 
-	if len(results) == 0 {
-		return TextResult("No results found."), nil, nil
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": doc.URI, "version": version},
+		"position":     map[string]any{"line": at.Line, "character": at.Character},
+		"pattern":      pattern,
+	}
+	result, err := sm.Client.Request(method, params)
+	if err != nil {
+		return ErrResult(err), nil, nil
 	}
 
-	var sb strings.Builder
-	fmt.Fprintf(&sb, "=== Search Results: %d ===\n", len(results))
-	for _, r := range results {
-		fmt.Fprintf(&sb, "%s : %s\n", r.Name, r.Statement)
+	text := RenderPpcmd(json.RawMessage(result))
+	if text == "" {
+		text = "No result."
 	}
-	return TextResult(sb.String()), nil, nil
+	return TextResult(text), nil, nil
 }
 
 // DoReset sends prover/resetRocq to reset the prover state for a document.
 func DoReset(sm *StateManager, file string) (*mcp.CallToolResult, any, error) {
-	sm.Mu.Lock()
-	doc, err := sm.GetDoc(file)
+	doc, release, err := sm.acquireProver(file)
 	if err != nil {
-		sm.Mu.Unlock()
 		return ErrResult(err), nil, nil
 	}
+	defer release()
+
+	sm.Mu.Lock()
 	DrainChannels(doc)
 	sm.Mu.Unlock()
 
@@ -225,42 +452,52 @@ func DoReset(sm *StateManager, file string) (*mcp.CallToolResult, any, error) {
 	sm.Mu.Lock()
 	doc.ProofView = nil
 	doc.Diagnostics = nil
+	doc.ExecPos = Position{}
 	sm.Mu.Unlock()
+	sm.emit(doc.URI, DocGoalsChanged)
+	sm.emit(doc.URI, DocDiagnosticsChanged)
 
 	return TextResult("Reset " + file), nil, nil
 }
 
-// DoDocumentProofs sends prover/documentProofs and returns the proof structure.
-func DoDocumentProofs(sm *StateManager, file string) (*mcp.CallToolResult, any, error) {
-	sm.Mu.Lock()
-	doc, err := sm.GetDoc(file)
-	sm.Mu.Unlock()
-	if err != nil {
-		return ErrResult(err), nil, nil
-	}
-
+// FetchDocumentProofs sends prover/documentProofs and returns the proof blocks.
+func FetchDocumentProofs(sm *StateManager, doc *DocState) ([]ProofBlock, error) {
 	params := map[string]any{
 		"textDocument": map[string]any{"uri": doc.URI},
 	}
 	result, err := sm.Client.Request("prover/documentProofs", params)
 	if err != nil {
-		return ErrResult(fmt.Errorf("parse documentProofs: %w", err)), nil, nil
+		return nil, fmt.Errorf("documentProofs: %w", err)
 	}
 
 	var resp struct {
 		Proofs []ProofBlock `json:"proofs"`
 	}
 	if err := json.Unmarshal(result, &resp); err != nil {
-		return ErrResult(fmt.Errorf("parse documentProofs: %w", err)), nil, nil
+		return nil, fmt.Errorf("parse documentProofs: %w", err)
+	}
+	return resp.Proofs, nil
+}
+
+// DoDocumentProofs sends prover/documentProofs and returns the proof structure.
+func DoDocumentProofs(sm *StateManager, file string) (*mcp.CallToolResult, any, error) {
+	doc, release, err := sm.acquireDoc(file)
+	if err != nil {
+		return ErrResult(err), nil, nil
 	}
+	defer release()
 
-	if len(resp.Proofs) == 0 {
+	proofs, err := FetchDocumentProofs(sm, doc)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	if len(proofs) == 0 {
 		return TextResult("No proofs found in " + file), nil, nil
 	}
 
 	var sb strings.Builder
-	fmt.Fprintf(&sb, "=== Proofs: %d ===\n", len(resp.Proofs))
-	for i, p := range resp.Proofs {
+	fmt.Fprintf(&sb, "=== Proofs: %d ===\n", len(proofs))
+	for i, p := range proofs {
 		fmt.Fprintf(&sb, "\n--- Proof %d (lines %d–%d) ---\n",
 			i+1, p.Range.Start.Line+1, p.Range.End.Line+1)
 		fmt.Fprintf(&sb, "Statement: %s\n", p.Statement.Statement)
@@ -274,24 +511,155 @@ func DoDocumentProofs(sm *StateManager, file string) (*mcp.CallToolResult, any,
 	return TextResult(sb.String()), nil, nil
 }
 
-// CollectSearchResults drains search results from the channel with a timeout.
-func CollectSearchResults(ch <-chan SearchResult) []SearchResult {
-	var results []SearchResult
-	timer := time.NewTimer(2 * time.Second)
-	defer timer.Stop()
-	for {
-		select {
-		case r := <-ch:
-			results = append(results, r)
-			if !timer.Stop() {
-				select {
-				case <-timer.C:
-				default:
-				}
-			}
-			timer.Reset(200 * time.Millisecond)
-		case <-timer.C:
-			return results
+// DoDocumentState sends prover/documentState and summarizes vsrocq's internal
+// view of the document: per-sentence execution status, errors, and unprocessed regions.
+func DoDocumentState(sm *StateManager, file string) (*mcp.CallToolResult, any, error) {
+	doc, release, err := sm.acquireDoc(file)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	defer release()
+
+	sm.Mu.Lock()
+	content := doc.Content
+	diags := doc.Diagnostics
+	sm.Mu.Unlock()
+
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": doc.URI},
+	}
+	result, err := sm.Client.Request("prover/documentState", params)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+
+	var resp struct {
+		Document string `json:"document"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return ErrResult(fmt.Errorf("parse documentState: %w", err)), nil, nil
+	}
+
+	sentences := ParseDocumentState(resp.Document)
+	MarkSentenceErrors(content, sentences, diags)
+	return TextResult(FormatDocumentState(content, sentences, diags)), nil, nil
+}
+
+// ProofName extracts the declared name from a proof statement such as
+// "Lemma foo : forall n, n = n." Returns "" for anonymous statements (Goal).
+func ProofName(statement string) string {
+	kw, rest := sentenceHead(statement)
+	if kw == "Goal" {
+		return ""
+	}
+	if !theoremKinds[kw] && !declKinds[kw] {
+		rest = kw + " " + rest
+	}
+	return outlineIdentRe.FindString(rest)
+}
+
+// FindProof returns the proof block whose statement declares name.
+// A qualified name matches on its last component.
+func FindProof(proofs []ProofBlock, name string) (*ProofBlock, error) {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	var names []string
+	for i := range proofs {
+		n := ProofName(proofs[i].Statement.Statement)
+		if n == name {
+			return &proofs[i], nil
+		}
+		if n != "" {
+			names = append(names, n)
 		}
 	}
+	return nil, fmt.Errorf("no proof named %q (proofs: %s)", name, strings.Join(names, ", "))
+}
+
+// proofTactics returns the tactic steps of a proof, without "Proof" and the
+// closing Qed/Defined/Admitted/Abort.
+func proofTactics(p *ProofBlock) []ProofStep {
+	var tactics []ProofStep
+	for _, s := range p.Steps {
+		kw, _ := sentenceHead(s.Tactic)
+		if _, ender := proofEnders[kw]; kw == "Proof" || ender {
+			continue
+		}
+		tactics = append(tactics, s)
+	}
+	return tactics
+}
+
+// ProofTarget resolves where to check a proof: "start" is just after the
+// statement, "end" is after the last tactic (before Qed), and "step" is after
+// the Nth tactic (1-indexed). It also returns the index of the last tactic
+// executed at that point, or -1 if none.
+func ProofTarget(p *ProofBlock, where string, step int) (Position, int, error) {
+	tactics := proofTactics(p)
+	switch where {
+	case "start":
+		return p.Statement.Range.End, -1, nil
+	case "", "end":
+		if len(tactics) == 0 {
+			return p.Statement.Range.End, -1, nil
+		}
+		return tactics[len(tactics)-1].Range.End, len(tactics) - 1, nil
+	case "step":
+		if step < 1 || step > len(tactics) {
+			return Position{}, 0, fmt.Errorf("step %d out of range (proof has %d tactics)", step, len(tactics))
+		}
+		return tactics[step-1].Range.End, step - 1, nil
+	}
+	return Position{}, 0, fmt.Errorf("unknown position %q (want start, end or step)", where)
+}
+
+// FormatProofTactics lists a proof's tactics, marking the last one executed.
+func FormatProofTactics(p *ProofBlock, current int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "=== Proof: %s (lines %d–%d) ===\n",
+		ProofName(p.Statement.Statement), p.Range.Start.Line+1, p.Range.End.Line+1)
+	tactics := proofTactics(p)
+	if len(tactics) == 0 {
+		sb.WriteString("(no tactics)\n")
+	}
+	for i, s := range tactics {
+		marker := " "
+		if i == current {
+			marker = ">"
+		}
+		fmt.Fprintf(&sb, "%s %d. L%d: %s\n", marker, i+1, s.Range.Start.Line+1, s.Tactic)
+	}
+	return sb.String()
+}
+
+// DoCheckProof checks a proof located by name rather than by coordinates and
+// returns its tactic list alongside the goals at the requested point.
+func DoCheckProof(sm *StateManager, file, name, where string, step int) (*mcp.CallToolResult, any, error) {
+	doc, release, err := sm.acquireProver(file)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	defer release()
+
+	proofs, err := FetchDocumentProofs(sm, doc)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	p, err := FindProof(proofs, name)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	pos, current, err := ProofTarget(p, where, step)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+
+	result, _, _ := checkAt(sm, doc, pos)
+	if result.IsError {
+		return result, nil, nil
+	}
+	header := &mcp.TextContent{Text: FormatProofTactics(p, current)}
+	result.Content = append([]mcp.Content{header}, result.Content...)
+	return result, nil, nil
 }
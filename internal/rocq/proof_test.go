@@ -0,0 +1,152 @@
+package rocq
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testProofBlock() *ProofBlock {
+	step := func(line int, tactic string) ProofStep {
+		return ProofStep{Tactic: tactic, Range: Range{
+			Start: Position{Line: line, Character: 2},
+			End:   Position{Line: line, Character: 2 + len(tactic)},
+		}}
+	}
+	return &ProofBlock{
+		Statement: ProofStatement{
+			Statement: "Theorem plus_0_n : forall n : nat, 0 + n = n.",
+			Range:     Range{End: Position{Line: 0, Character: 45}},
+		},
+		Range: Range{End: Position{Line: 5, Character: 4}},
+		Steps: []ProofStep{
+			step(1, "Proof."),
+			step(2, "intros n."),
+			step(3, "simpl."),
+			step(4, "reflexivity."),
+			step(5, "Qed."),
+		},
+	}
+}
+
+func TestProofName(t *testing.T) {
+	tests := []struct {
+		statement, want string
+	}{
+		{"Theorem plus_0_n : forall n : nat, 0 + n = n.", "plus_0_n"},
+		{"#[local] Lemma foo' (n : nat) : n = n.", "foo'"},
+		{"Program Definition bar : nat.", "bar"},
+		{"Goal True.", ""},
+		{"baz : True", "baz"},
+	}
+	for _, tt := range tests {
+		if got := ProofName(tt.statement); got != tt.want {
+			t.Errorf("ProofName(%q) = %q, want %q", tt.statement, got, tt.want)
+		}
+	}
+}
+
+func TestFindProof(t *testing.T) {
+	proofs := []ProofBlock{*testProofBlock()}
+	if _, err := FindProof(proofs, "Top.plus_0_n"); err != nil {
+		t.Errorf("FindProof qualified: %v", err)
+	}
+	if _, err := FindProof(proofs, "missing"); err == nil {
+		t.Error("expected error for missing proof")
+	}
+}
+
+func TestProofTarget(t *testing.T) {
+	p := testProofBlock()
+	tests := []struct {
+		where       string
+		step        int
+		wantLine    int
+		wantCurrent int
+		wantErr     bool
+	}{
+		{"start", 0, 0, -1, false},
+		{"end", 0, 4, 2, false},
+		{"", 0, 4, 2, false},
+		{"step", 1, 2, 0, false},
+		{"step", 3, 4, 2, false},
+		{"step", 4, 0, 0, true},
+		{"middle", 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		pos, current, err := ProofTarget(p, tt.where, tt.step)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s %d: err = %v, wantErr %v", tt.where, tt.step, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (pos.Line != tt.wantLine || current != tt.wantCurrent) {
+			t.Errorf("%s %d: got line %d current %d, want line %d current %d",
+				tt.where, tt.step, pos.Line, current, tt.wantLine, tt.wantCurrent)
+		}
+	}
+}
+
+func TestFormatProofTactics(t *testing.T) {
+	got := FormatProofTactics(testProofBlock(), 1)
+	want := `=== Proof: plus_0_n (lines 1–6) ===
+  1. L3: intros n.
+> 2. L4: simpl.
+  3. L5: reflexivity.
+`
+	if got != want {
+		t.Errorf("mismatch.\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestCheckToEnd(t *testing.T) {
+	sm, prover := newStuckStateManager()
+	defer sm.Shutdown()
+	path := writeTestFile(t, t.TempDir(), "a.v", "Definition n := 1.\nCompute slow.\n")
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatal(err)
+	}
+	uri := FileURI(path)
+
+	diags, err := CheckToEnd(sm, path, NotifyTimeout)
+	if err != nil || len(diags) != 1 || diags[0].Message != "ran" {
+		t.Fatalf("CheckToEnd = %v, %v", diags, err)
+	}
+
+	prover.stuck.Store(true)
+	if _, err := CheckToEnd(sm, path, 200*time.Millisecond); err == nil || !strings.Contains(err.Error(), "timed out after 200ms") {
+		t.Errorf("stuck check: %v", err)
+	}
+
+	// Diagnostics published while vsrocq is still processing are not the result.
+	type outcome struct {
+		diags []Diagnostic
+		err   error
+	}
+	done := make(chan outcome)
+	go func() {
+		diags, err := CheckToEnd(sm, path, NotifyTimeout)
+		done <- outcome{diags, err}
+	}()
+	waitFor(t, "the check to wait on vsrocq", func() bool {
+		sm.Mu.Lock()
+		defer sm.Mu.Unlock()
+		return sm.Docs[uri].interrupt != nil
+	})
+	highlights := func(processing []any) {
+		prover.codec.encode(map[string]any{"jsonrpc": "2.0", "method": "prover/updateHighlights",
+			"params": map[string]any{"uri": uri, "processingRange": processing}})
+	}
+	highlights([]any{map[string]any{"start": map[string]any{"line": 1, "character": 0}, "end": map[string]any{"line": 1, "character": 13}}})
+	prover.publish(uri, 0, "ran")
+	select {
+	case o := <-done:
+		t.Fatalf("check returned while vsrocq was processing: %v, %v", o.diags, o.err)
+	case <-time.After(3 * settleTime):
+	}
+	highlights(nil)
+	prover.publish(uri, 1, "Slow failure.")
+	o := <-done
+	if o.err != nil || len(o.diags) != 1 || o.diags[0].Message != "Slow failure." {
+		t.Errorf("CheckToEnd = %v, %v", o.diags, o.err)
+	}
+}
@@ -0,0 +1,253 @@
+package rocq
+
+// record.go — session transcripts: recording tool calls and LSP traffic, and
+// replaying recorded prover responses in place of vsrocqtop.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Transcript entry kinds.
+const (
+	RecordServer     = "server" // the server's own flags, first in a transcript
+	RecordToolCall   = "tool_call"
+	RecordToolResult = "tool_result"
+	RecordLSPSend    = "lsp_send"
+	RecordLSPRecv    = "lsp_recv"
+)
+
+// RecordEntry is one line of a session transcript.
+type RecordEntry struct {
+	Time    time.Time       `json:"time"`
+	Kind    string          `json:"kind"`
+	Tool    string          `json:"tool,omitempty"`
+	Args    json.RawMessage `json:"args,omitempty"`
+	Result  string          `json:"result,omitempty"`
+	IsError bool            `json:"is_error,omitempty"`
+	Message json.RawMessage `json:"message,omitempty"` // raw JSON-RPC body
+	Flags   []string        `json:"flags,omitempty"`   // rocq-mcp flags, before vsrocqtop's
+}
+
+// Recorder appends transcript entries to a JSONL file. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu   sync.Mutex
+	f    *os.File
+	enc  *json.Encoder
+	Path string
+}
+
+// NewRecorder creates dir if needed and opens a new transcript file in it.
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("session-%s-%d.jsonl", time.Now().Format("20060102-150405"), os.Getpid())
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{f: f, enc: json.NewEncoder(f), Path: path}, nil
+}
+
+// Record appends e, stamping it with the current time if it has none.
+// Write errors are dropped: recording must never break a session.
+func (r *Recorder) Record(e RecordEntry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enc.Encode(e)
+}
+
+// recordLSP is a tapFunc recording raw LSP bodies.
+func (r *Recorder) recordLSP(dir string, body []byte) {
+	kind := RecordLSPSend
+	if dir == "recv" {
+		kind = RecordLSPRecv
+	}
+	r.Record(RecordEntry{Kind: kind, Message: append(json.RawMessage(nil), body...)})
+}
+
+// Close closes the transcript file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// SetRecorder records all LSP traffic of clients started after the call to r.
+func (sm *StateManager) SetRecorder(r *Recorder) {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	sm.recorder = r
+}
+
+// ReadTranscript reads a JSONL transcript written by a Recorder.
+func ReadTranscript(path string) ([]RecordEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []RecordEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 64<<20)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e RecordEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
+
+// UseTranscriptProver makes sm talk to a fake prover that answers from the
+// LSP traffic in entries instead of starting vsrocqtop.
+func (sm *StateManager) UseTranscriptProver(entries []RecordEntry) {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	sm.startClient = func(_ []string, tap tapFunc) (*VsrocqClient, error) {
+		toProver, fromClient := io.Pipe()
+		fromProver, toClient := io.Pipe()
+		p := newTranscriptProver(entries, newLSPCodec(toProver, toClient))
+		go p.serve()
+		return newClientConn(fromProver, fromClient, tap), nil
+	}
+}
+
+// transcriptProver plays back recorded prover messages. Each message the
+// client sends is matched to the next recorded lsp_send with the same method,
+// and the lsp_recv entries that followed it are sent back. Request IDs and
+// string "id" params (search IDs) are remapped from recorded to live values.
+type transcriptProver struct {
+	entries []RecordEntry
+	next    int // index of the first entry not yet matched
+	codec   *lspCodec
+	ids     map[int64]int64   // recorded request ID → live request ID
+	strIDs  map[string]string // recorded params.id → live params.id
+}
+
+func newTranscriptProver(entries []RecordEntry, codec *lspCodec) *transcriptProver {
+	return &transcriptProver{
+		entries: entries,
+		codec:   codec,
+		ids:     make(map[int64]int64),
+		strIDs:  make(map[string]string),
+	}
+}
+
+// serve answers client messages until the connection closes. Messages are
+// read on a separate goroutine so a client replying inline from its read loop
+// (as it does for workspace/configuration) never blocks against our writes.
+func (p *transcriptProver) serve() {
+	inbox := make(chan *rawMessage, 1024)
+	go func() {
+		defer close(inbox)
+		for {
+			msg, err := p.codec.decode()
+			if err != nil {
+				return
+			}
+			inbox <- msg
+		}
+	}()
+	for msg := range inbox {
+		p.answer(msg)
+	}
+}
+
+func (p *transcriptProver) answer(msg *rawMessage) {
+	k := p.match(msg)
+	if k < 0 {
+		// Unrecorded request: answer with a null result so the caller does not hang.
+		if msg.ID != nil && msg.Method != nil {
+			p.codec.encode(map[string]any{"jsonrpc": "2.0", "id": *msg.ID, "result": nil})
+		}
+		return
+	}
+	p.next = k + 1
+	for ; p.next < len(p.entries); p.next++ {
+		e := p.entries[p.next]
+		if e.Kind == RecordLSPSend {
+			break
+		}
+		if e.Kind == RecordLSPRecv {
+			p.codec.encode(p.remap(e.Message))
+		}
+	}
+}
+
+// match finds the next recorded lsp_send corresponding to msg and records the
+// ID mappings it implies, returning its index or -1.
+func (p *transcriptProver) match(msg *rawMessage) int {
+	for k := p.next; k < len(p.entries); k++ {
+		e := p.entries[k]
+		if e.Kind != RecordLSPSend {
+			continue
+		}
+		var rec rawMessage
+		if json.Unmarshal(e.Message, &rec) != nil {
+			continue
+		}
+		if (rec.Method == nil) != (msg.Method == nil) || rec.Method != nil && *rec.Method != *msg.Method {
+			continue
+		}
+		if rec.ID != nil && msg.ID != nil && rec.Method != nil {
+			p.ids[*rec.ID] = *msg.ID
+		}
+		if recID, liveID := paramsID(rec.Params), paramsID(msg.Params); recID != "" && liveID != "" {
+			p.strIDs[recID] = liveID
+		}
+		return k
+	}
+	return -1
+}
+
+// remap rewrites a recorded prover message to use live IDs.
+func (p *transcriptProver) remap(body json.RawMessage) json.RawMessage {
+	var m map[string]any
+	if json.Unmarshal(body, &m) != nil {
+		return body
+	}
+	if id, ok := m["id"].(float64); ok && m["method"] == nil {
+		if live, ok := p.ids[int64(id)]; ok {
+			m["id"] = live
+		}
+	}
+	if params, ok := m["params"].(map[string]any); ok {
+		if id, ok := params["id"].(string); ok {
+			if live, ok := p.strIDs[id]; ok {
+				params["id"] = live
+			}
+		}
+	}
+	out, err := json.Marshal(m)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// paramsID returns the string "id" field of JSON-RPC params, if any.
+func paramsID(params json.RawMessage) string {
+	var v struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(params, &v)
+	return v.ID
+}
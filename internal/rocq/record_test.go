@@ -0,0 +1,75 @@
+package rocq
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorderRoundTrip(t *testing.T) {
+	rec, err := NewRecorder(filepath.Join(t.TempDir(), "sessions"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Record(RecordEntry{Kind: RecordToolCall, Tool: "rocq_open", Args: json.RawMessage(`{"file":"a.v"}`)})
+	rec.recordLSP("send", []byte(`{"jsonrpc":"2.0","method":"initialized"}`))
+	rec.recordLSP("recv", []byte(`{"jsonrpc":"2.0","id":1,"result":null}`))
+	rec.Record(RecordEntry{Kind: RecordToolResult, Tool: "rocq_open", Result: "Opened a.v"})
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadTranscript(rec.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, e := range entries {
+		if e.Time.IsZero() {
+			t.Errorf("entry %s has no timestamp", e.Kind)
+		}
+		kinds = append(kinds, e.Kind)
+	}
+	if got, want := strings.Join(kinds, ","), "tool_call,lsp_send,lsp_recv,tool_result"; got != want {
+		t.Errorf("kinds = %s, want %s", got, want)
+	}
+	if string(entries[0].Args) != `{"file":"a.v"}` || entries[3].Result != "Opened a.v" {
+		t.Errorf("entries = %+v", entries)
+	}
+}
+
+func TestTranscriptProverSearch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.v")
+	if err := os.WriteFile(path, []byte("Lemma foo : True.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Recorded IDs deliberately differ from the ones the live client will use.
+	lsp := func(kind, body string) RecordEntry {
+		return RecordEntry{Kind: kind, Message: json.RawMessage(body)}
+	}
+	entries := []RecordEntry{
+		lsp(RecordLSPSend, `{"jsonrpc":"2.0","id":7,"method":"initialize","params":{}}`),
+		lsp(RecordLSPRecv, `{"jsonrpc":"2.0","id":7,"result":{"capabilities":{}}}`),
+		lsp(RecordLSPSend, `{"jsonrpc":"2.0","method":"initialized","params":{}}`),
+		lsp(RecordLSPSend, `{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{}}`),
+		lsp(RecordLSPSend, `{"jsonrpc":"2.0","id":8,"method":"prover/search","params":{"id":"search-1","pattern":"foo"}}`),
+		lsp(RecordLSPRecv, `{"jsonrpc":"2.0","id":8,"result":null}`),
+		lsp(RecordLSPRecv, `{"jsonrpc":"2.0","method":"prover/searchResult","params":{"id":"search-1","name":"foo","statement":"True"}}`),
+	}
+
+	sm := NewStateManager(nil)
+	sm.UseTranscriptProver(entries)
+	defer sm.Shutdown()
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatal(err)
+	}
+
+	res, _, _ := DoSearch(sm, path, "foo", nil, SearchOptions{})
+	want := "=== Search Results: 1–1 of 1 ===\nfoo : True\n"
+	if got := resultText(res); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
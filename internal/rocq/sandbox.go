@@ -0,0 +1,195 @@
+package rocq
+
+// sandbox.go — confining file access: the roots document paths must lie
+// under, and running vsrocqtop under OS restrictions so that the files it
+// checks cannot write outside the project (see sandbox_linux.go).
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrOutsideRoots is returned for a file argument outside the allowed roots.
+var ErrOutsideRoots = errors.New("path outside the allowed roots")
+
+// SetAllowedRoots restricts the files that documents may be opened, synced,
+// checkpointed and restored from to those under roots. Paths are compared
+// after resolving symlinks, so a link inside a root cannot reach a file
+// outside it. With no roots, any path is allowed.
+func (sm *StateManager) SetAllowedRoots(roots []string) {
+	var canon []string
+	for _, r := range roots {
+		canon = append(canon, CanonicalPath(r))
+	}
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	sm.allowed = canon
+}
+
+// AllowedRoots returns the canonical roots set by SetAllowedRoots.
+func (sm *StateManager) AllowedRoots() []string {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	return slices.Clone(sm.allowed)
+}
+
+// checkPath returns the canonical form of path if it lies under an allowed
+// root, and path unchanged if no roots are set.
+func (sm *StateManager) checkPath(path string) (string, error) {
+	roots := sm.AllowedRoots()
+	if len(roots) == 0 {
+		return path, nil
+	}
+	canon := CanonicalPath(path)
+	for _, root := range roots {
+		if withinRoot(canon, root) {
+			return canon, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s (allowed: %s)", ErrOutsideRoots, path, strings.Join(roots, ", "))
+}
+
+// withinRoot reports whether path is root or lies beneath it. Both must be canonical.
+func withinRoot(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || filepath.IsAbs(rel) {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// readFile reads a file named by a tool argument, if it is allowed.
+func (sm *StateManager) readFile(path string) ([]byte, error) {
+	canon, err := sm.checkPath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(canon)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	return data, nil
+}
+
+// writeFile writes a file named by a tool argument, if it is allowed.
+func (sm *StateManager) writeFile(path string, data []byte) error {
+	canon, err := sm.checkPath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(canon, data, 0o644); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	return nil
+}
+
+// SandboxExecCommand is the subcommand under which a program re-executes
+// itself to apply the prover sandbox or memory limit before running
+// vsrocqtop. Programs that call SandboxProver or set a memory limit must
+// dispatch it to RunSandboxed.
+const SandboxExecCommand = "sandbox-exec"
+
+// SandboxProver makes the prover run under OS restrictions that allow it to
+// write only beneath the writable directories (see execRestricted). It fails
+// if the restrictions are unavailable, rather than running unconfined.
+func (sm *StateManager) SandboxProver(writable []string) error {
+	if err := sandboxSupported(); err != nil {
+		return err
+	}
+	if _, err := os.Executable(); err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+	var canon []string
+	for _, dir := range writable {
+		canon = append(canon, CanonicalPath(dir))
+	}
+
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	sm.sandboxed, sm.writable = true, canon
+	return nil
+}
+
+// startProver starts vsrocqtop with args. Under the sandbox or a memory
+// limit it does so through SandboxExecCommand, which applies them to its own
+// process before executing vsrocqtop, so that they hold from the start.
+func (sm *StateManager) startProver(args []string, tap tapFunc) (*VsrocqClient, error) {
+	sm.Mu.Lock()
+	sandboxed, writable, memory := sm.sandboxed, sm.writable, sm.limits.Memory
+	sm.Mu.Unlock()
+	if !sandboxed && memory == 0 {
+		return newVsrocqClient(args, tap)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("start vsrocqtop: %w", err)
+	}
+	argv := []string{exe, SandboxExecCommand}
+	for _, dir := range writable {
+		argv = append(argv, "--write", dir)
+	}
+	if !sandboxed {
+		argv = append(argv, "--unconfined")
+	}
+	if memory > 0 {
+		argv = append(argv, "--max-memory", strconv.FormatInt(memory, 10))
+	}
+	argv = append(argv, "--", "vsrocqtop")
+	return startVsrocq(append(argv, args...), tap)
+}
+
+// RunSandboxed implements SandboxExecCommand: given
+// "[--write DIR]... [--unconfined] [--max-memory BYTES] -- PROGRAM ARGS...",
+// it caps its address space at BYTES, restricts the process unless
+// --unconfined is given, and then replaces it with PROGRAM. It returns only
+// on failure.
+func RunSandboxed(args []string) error {
+	var writable []string
+	var memory int64
+	confine := true
+	for len(args) > 0 && args[0] != "--" {
+		switch {
+		case args[0] == "--unconfined":
+			confine = false
+			args = args[1:]
+			continue
+		case args[0] == "--write" && len(args) >= 2:
+			writable = append(writable, args[1])
+		case args[0] == "--max-memory" && len(args) >= 2:
+			n, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("%s: invalid memory limit %q", SandboxExecCommand, args[1])
+			}
+			memory = n
+		default:
+			return fmt.Errorf("%s: unexpected argument %q", SandboxExecCommand, args[0])
+		}
+		args = args[2:]
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("%s: no program given", SandboxExecCommand)
+	}
+	argv := args[1:]
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", SandboxExecCommand, err)
+	}
+	if memory > 0 {
+		if err := limitMemory(memory); err != nil {
+			return fmt.Errorf("%s: memory limit: %w", SandboxExecCommand, err)
+		}
+	}
+	if confine {
+		err = execRestricted(path, argv, writable)
+	} else {
+		err = execProgram(path, argv)
+	}
+	return fmt.Errorf("%s: %w", SandboxExecCommand, err)
+}
@@ -0,0 +1,167 @@
+package rocq
+
+// sandbox_linux.go — the prover sandbox on Linux: Landlock confines writes to
+// the project, and a seccomp filter denies network sockets. Reads are not
+// restricted, since vsrocqtop loads libraries from wherever Rocq is installed.
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Landlock filesystem rights that modify the filesystem. These are the
+// rights the ruleset handles; everything else (reads, execution) stays allowed.
+const landlockWriteAccess = unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+	unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+	unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+	unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+	unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+	unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+	unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+	unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+	unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+	unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+
+// landlockABI returns the kernel's Landlock ABI version.
+func landlockABI() (int, error) {
+	v, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0, fmt.Errorf("landlock unavailable: %w", errno)
+	}
+	return int(v), nil
+}
+
+func sandboxSupported() error {
+	_, err := landlockABI()
+	return err
+}
+
+// execRestricted confines the calling thread and replaces the process with
+// path. Landlock and seccomp apply per thread and are inherited across
+// execve, so the thread stays locked from restriction to exec.
+func execRestricted(path string, argv, writable []string) error {
+	runtime.LockOSThread()
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("no_new_privs: %w", err)
+	}
+	if err := restrictWrites(writable); err != nil {
+		return err
+	}
+	if err := denySockets(); err != nil {
+		return err
+	}
+	return unix.Exec(path, argv, os.Environ())
+}
+
+// restrictWrites installs a Landlock ruleset that permits modifying the
+// filesystem only beneath the writable directories, and writing /dev/null.
+func restrictWrites(writable []string) error {
+	abi, err := landlockABI()
+	if err != nil {
+		return err
+	}
+	handled := uint64(landlockWriteAccess)
+	if abi >= 2 {
+		handled |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		handled |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+
+	// Only the filesystem field is passed, which every ABI version accepts.
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET,
+		uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr.Access_fs), 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock create ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer unix.Close(ruleset)
+
+	for _, dir := range writable {
+		if err := landlockAllow(ruleset, dir, handled); err != nil {
+			return err
+		}
+	}
+	fileRights := handled & (unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE)
+	if err := landlockAllow(ruleset, os.DevNull, fileRights); err != nil {
+		return err
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("landlock restrict: %w", errno)
+	}
+	return nil
+}
+
+// landlockAllow grants access beneath path in the ruleset.
+func landlockAllow(ruleset int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("landlock: open %s: %w", path, err)
+	}
+	defer unix.Close(fd)
+	rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset),
+		unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock: allow %s: %w", path, errno)
+	}
+	return nil
+}
+
+// seccompArch gives, per GOARCH, the audit architecture and socket(2)
+// syscall number the filter checks. On other architectures no filter is
+// installed.
+var seccompArch = map[string]struct {
+	audit  uint32
+	socket uint32
+}{
+	"amd64": {unix.AUDIT_ARCH_X86_64, 41},
+	"arm64": {unix.AUDIT_ARCH_AARCH64, 198},
+}
+
+// denySockets installs a seccomp filter under which socket(2) fails with
+// EACCES for every domain but AF_UNIX, so checked files cannot reach the
+// network. Syscalls through other ABIs (i386, x32), which could reach sockets
+// under other numbers, fail outright.
+func denySockets() error {
+	arch, ok := seccompArch[runtime.GOARCH]
+	if !ok {
+		return nil
+	}
+	const (
+		ld  = unix.BPF_LD | unix.BPF_W | unix.BPF_ABS
+		jeq = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+		jge = unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K
+		ret = unix.BPF_RET | unix.BPF_K
+	)
+	// Offsets into struct seccomp_data.
+	const (
+		nrOff   = 0
+		archOff = 4
+		arg0Off = 16 // low word of args[0] (little-endian)
+	)
+	const x32SyscallBit = 0x40000000
+	filter := []unix.SockFilter{
+		/* 0 */ {Code: ld, K: archOff},
+		/* 1 */ {Code: jeq, Jt: 0, Jf: 5, K: arch.audit}, // foreign ABI → deny
+		/* 2 */ {Code: ld, K: nrOff},
+		/* 3 */ {Code: jge, Jt: 3, Jf: 0, K: x32SyscallBit}, // x32 → deny
+		/* 4 */ {Code: jeq, Jt: 0, Jf: 3, K: arch.socket}, // not socket → allow
+		/* 5 */ {Code: ld, K: arg0Off},
+		/* 6 */ {Code: jeq, Jt: 1, Jf: 0, K: unix.AF_UNIX}, // AF_UNIX → allow
+		/* 7 */ {Code: ret, K: unix.SECCOMP_RET_ERRNO | uint32(unix.EACCES)},
+		/* 8 */ {Code: ret, K: unix.SECCOMP_RET_ALLOW},
+	}
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0)
+	if err != nil {
+		return fmt.Errorf("seccomp: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package rocq
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestSandboxHelper is run as a subprocess by TestRunSandboxed.
+func TestSandboxHelper(t *testing.T) {
+	dir := os.Getenv("ROCQ_SANDBOX_DIR")
+	if dir == "" {
+		t.Skip("helper for TestRunSandboxed")
+	}
+	script := `echo ok > "$1/inside/ok" && echo no > "$1/outside/no"; ` +
+		`echo $? > "$1/inside/status"; echo x > /dev/null`
+	err := RunSandboxed([]string{"--write", filepath.Join(dir, "inside"), "--", "sh", "-c", script, "sh", dir})
+	t.Fatal(err)
+}
+
+func TestRunSandboxed(t *testing.T) {
+	if err := sandboxSupported(); err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	for _, d := range []string{"inside", "outside"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSandboxHelper$")
+	cmd.Env = append(os.Environ(), "ROCQ_SANDBOX_DIR="+dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("sandboxed shell: %v\n%s", err, out)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "inside", "ok")); err != nil {
+		t.Errorf("write inside the writable root: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside", "no")); err == nil {
+		t.Error("write outside the writable root succeeded")
+	}
+	status, _ := os.ReadFile(filepath.Join(dir, "inside", "status"))
+	if string(status) == "0\n" {
+		t.Error("shell reported success writing outside the root")
+	}
+}
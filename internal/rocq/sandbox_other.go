@@ -0,0 +1,11 @@
+//go:build !linux
+
+package rocq
+
+import "errors"
+
+var errNoSandbox = errors.New("the prover sandbox requires Linux (Landlock)")
+
+func sandboxSupported() error { return errNoSandbox }
+
+func execRestricted(path string, argv, writable []string) error { return errNoSandbox }
@@ -0,0 +1,98 @@
+package rocq
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	inside := writeTestFile(t, root, "a.v", "")
+	secret := writeTestFile(t, outside, "secret.v", "")
+	if err := os.Symlink(secret, filepath.Join(root, "escape.v")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(inside, filepath.Join(root, "alias.v")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(root+"-sibling", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root + "-sibling")
+
+	sm := newEchoStateManager()
+	sm.SetAllowedRoots([]string{root})
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{inside, true},
+		{filepath.Join(root, "new.v"), true}, // not yet created
+		{filepath.Join(root, "alias.v"), true},
+		{root, true},
+		{secret, false},
+		{filepath.Join(root, "..", filepath.Base(outside), "secret.v"), false},
+		{filepath.Join(root, "escape.v"), false},
+		{filepath.Join(root, "escape", "secret.v"), false},
+		{filepath.Join(root+"-sibling", "b.v"), false},
+	}
+	for _, tt := range tests {
+		_, err := sm.checkPath(tt.path)
+		if tt.ok && err != nil || !tt.ok && !errors.Is(err, ErrOutsideRoots) {
+			t.Errorf("checkPath(%s) = %v, want ok=%v", tt.path, err, tt.ok)
+		}
+	}
+
+	sm.SetAllowedRoots(nil)
+	if got, err := sm.checkPath(secret); err != nil || got != secret {
+		t.Errorf("with no roots: checkPath = %q, %v", got, err)
+	}
+}
+
+func TestAllowedRootsEnforced(t *testing.T) {
+	sm := newEchoStateManager()
+	defer sm.Shutdown()
+	root := t.TempDir()
+	inside := writeTestFile(t, root, "a.v", "Lemma foo : True.\n")
+	secret := writeTestFile(t, t.TempDir(), "secret.v", "")
+	link := filepath.Join(root, "link.v")
+	if err := os.Symlink(secret, link); err != nil {
+		t.Fatal(err)
+	}
+	sm.SetAllowedRoots([]string{root})
+
+	for _, path := range []string{secret, link} {
+		if err := sm.OpenDoc(path); !errors.Is(err, ErrOutsideRoots) {
+			t.Errorf("OpenDoc(%s) = %v, want ErrOutsideRoots", path, err)
+		}
+	}
+	if err := sm.OpenDoc(inside); err != nil {
+		t.Fatal(err)
+	}
+
+	// Retargeting a link after opening does not let sync read through it.
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(inside, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.OpenDoc(link); !errors.Is(err, ErrAlreadyOpen) {
+		t.Fatalf("OpenDoc(link to open file) = %v", err)
+	}
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.SyncDoc(link); err == nil {
+		t.Error("SyncDoc through a retargeted link succeeded")
+	}
+}
@@ -0,0 +1,265 @@
+package rocq
+
+// search.go — search query construction, result collection, ranking, and paging.
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// DefaultSearchLimit is the page size used when SearchOptions.Limit is zero.
+const DefaultSearchLimit = 50
+
+// SearchOptions controls how a search is issued and how its results are paged.
+type SearchOptions struct {
+	Kind    string   // "search" (default), "pattern" or "rewrite"
+	Inside  []string // restrict to these modules (Rocq's "inside")
+	Outside []string // exclude these modules (Rocq's "outside")
+	Name    string   // regexp filter on result names
+	Limit   int      // page size; 0 means DefaultSearchLimit
+	Offset  int      // index of the first result to return
+}
+
+// BuildSearchQuery turns a pattern and options into the query text vsrocq parses
+// as a Search command. SearchPattern and SearchRewrite are expressed with
+// headconcl: clauses, since vsrocq only runs Search.
+func BuildSearchQuery(pattern string, opts SearchOptions) (string, error) {
+	var query string
+	switch opts.Kind {
+	case "", "search":
+		query = pattern
+	case "pattern":
+		query = fmt.Sprintf("headconcl:(%s)", pattern)
+	case "rewrite":
+		query = fmt.Sprintf("[headconcl:(%s = _) | headconcl:(_ = %s)]", pattern, pattern)
+	default:
+		return "", fmt.Errorf("unknown search kind %q (want search, pattern or rewrite)", opts.Kind)
+	}
+
+	if len(opts.Inside) > 0 && len(opts.Outside) > 0 {
+		return "", fmt.Errorf("cannot restrict search both inside and outside modules")
+	}
+	// Module names go into the command verbatim, so anything but a qualified
+	// identifier could end the sentence and start another.
+	for _, m := range slices.Concat(opts.Inside, opts.Outside) {
+		if !qualidRe.MatchString(m) {
+			return "", fmt.Errorf("invalid module name %q (want a qualified identifier such as Coq.Lists.List)", m)
+		}
+	}
+	if len(opts.Inside) > 0 {
+		query += " inside " + strings.Join(opts.Inside, " ")
+	}
+	if len(opts.Outside) > 0 {
+		query += " outside " + strings.Join(opts.Outside, " ")
+	}
+	return query, nil
+}
+
+// qualidRe matches a Rocq qualified identifier.
+var qualidRe = regexp.MustCompile(`^[\pL_][\pL\pN_']*(?:\.[\pL_][\pL\pN_']*)*$`)
+
+// DoSearch sends a search request and collects results from prover/searchResult notifications.
+// The search runs in the context at pos (nil means the current execution point).
+// Results are filtered by name, ranked, and paged according to opts.
+func DoSearch(sm *StateManager, file string, pattern string, pos *Position, opts SearchOptions) (*mcp.CallToolResult, any, error) {
+	query, err := BuildSearchQuery(pattern, opts)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	var nameRe *regexp.Regexp
+	if opts.Name != "" {
+		nameRe, err = regexp.Compile(opts.Name)
+		if err != nil {
+			return ErrResult(fmt.Errorf("invalid name filter: %w", err)), nil, nil
+		}
+	}
+
+	doc, release, err := sm.acquireDoc(file)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	defer release()
+
+	sm.Mu.Lock()
+	at := queryPosition(doc, pos)
+	content := doc.Content
+	module := strings.TrimSuffix(filepath.Base(URIPath(doc.URI)), ".v")
+	sm.Mu.Unlock()
+
+	results, err := runSearch(sm, doc, at, query)
+	if err != nil {
+		return ErrResult(err), nil, nil
+	}
+	if nameRe != nil {
+		results = slices.DeleteFunc(results, func(r SearchResult) bool {
+			return !nameRe.MatchString(r.Name)
+		})
+	}
+	RankSearchResults(results, localQualifiedNames(content), module)
+	return TextResult(FormatSearchResults(results, opts.Offset, opts.Limit)), nil, nil
+}
+
+// searchSeq numbers searches so concurrent ones get distinct IDs.
+var searchSeq atomic.Int64
+
+// runSearch sends prover/search for query at position at and collects the
+// results streamed back as prover/searchResult notifications.
+func runSearch(sm *StateManager, doc *DocState, at Position, query string) ([]SearchResult, error) {
+	// Register a channel to collect search results before sending the request.
+	searchID := fmt.Sprintf("search-%d", searchSeq.Add(1))
+	resultCh := make(chan SearchResult, 256)
+	sm.RegisterSearchHandler(searchID, resultCh)
+	defer sm.UnregisterSearchHandler(searchID)
+
+	sm.Mu.Lock()
+	version := doc.Version
+	sm.Mu.Unlock()
+
+	params := map[string]any{
+		"textDocument": map[string]any{"uri": doc.URI, "version": version},
+		"position":     map[string]any{"line": at.Line, "character": at.Character},
+		"pattern":      query,
+		"id":           searchID,
+	}
+	// Collect from before the request is sent: results may arrive ahead of the
+	// reply, and the LSP read loop that delivers both blocks while resultCh is
+	// full, so nothing may wait on the reply without draining resultCh.
+	replied := make(chan struct{})
+	collected := make(chan []SearchResult, 1)
+	go func() { collected <- CollectSearchResults(resultCh, replied) }()
+
+	_, err := sm.Client.Request("prover/search", params)
+	close(replied)
+	results := <-collected
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// RankSearchResults sorts results in place: names declared in the current file
+// first, then shorter statements, then by name. local holds the file's
+// declarations qualified by their enclosing modules (see localQualifiedNames);
+// module is the file's own module name, which Rocq prefixes (possibly after a
+// library path) when the short name is ambiguous.
+func RankSearchResults(results []SearchResult, local map[string]bool, module string) {
+	isLocal := func(r SearchResult) int {
+		name := r.Name
+		if local[name] {
+			return 0
+		}
+		if module == "" {
+			return 1
+		}
+		if i := strings.LastIndex("."+name, "."+module+"."); i >= 0 && local[name[i+len(module)+1:]] {
+			return 0
+		}
+		return 1
+	}
+	slices.SortStableFunc(results, func(a, b SearchResult) int {
+		return cmp.Or(
+			cmp.Compare(isLocal(a), isLocal(b)),
+			cmp.Compare(len(a.Statement), len(b.Statement)),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+}
+
+// FormatSearchResults renders one page of results with the total count and,
+// if more remain, the offset to continue from.
+func FormatSearchResults(results []SearchResult, offset, limit int) string {
+	if len(results) == 0 {
+		return "No results found."
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	offset = max(offset, 0)
+	if offset >= len(results) {
+		return fmt.Sprintf("No results at offset %d (total %d).", offset, len(results))
+	}
+	end := min(offset+limit, len(results))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "=== Search Results: %d–%d of %d ===\n", offset+1, end, len(results))
+	for _, r := range results[offset:end] {
+		fmt.Fprintf(&sb, "%s : %s\n", r.Name, r.Statement)
+	}
+	if end < len(results) {
+		fmt.Fprintf(&sb, "\n(%d more; continue with offset=%d)\n", len(results)-end, end)
+	}
+	return sb.String()
+}
+
+// CollectSearchResults drains search results from the channel until replied
+// is closed and results have stopped arriving: vsrocq streams them after its
+// reply without marking the last one.
+func CollectSearchResults(ch <-chan SearchResult, replied <-chan struct{}) []SearchResult {
+	var results []SearchResult
+	var timer *time.Timer
+	var timeout <-chan time.Time // nil until replied
+	for {
+		select {
+		case r := <-ch:
+			results = append(results, r)
+			if timer != nil {
+				timer.Reset(200 * time.Millisecond)
+			}
+		case <-replied:
+			replied = nil
+			wait := 2 * time.Second
+			if len(results) > 0 {
+				wait = 200 * time.Millisecond
+			}
+			timer = time.NewTimer(wait)
+			defer timer.Stop()
+			timeout = timer.C
+		case <-timeout:
+			return results
+		}
+	}
+}
+
+var declNameRe = regexp.MustCompile(`(?m)^\s*(?:#\[[^\]]*\]\s*)?(?:(?:Local|Global|Polymorphic|Program|Private)\s+)*` +
+	`(?:Theorem|Lemma|Fact|Remark|Corollary|Proposition|Property|Example|Definition|Fixpoint|CoFixpoint|` +
+	`Inductive|CoInductive|Variant|Record|Structure|Class|Instance|Axiom|Parameter|Conjecture|Hypothesis|Variable|Let)` +
+	`\s+([A-Za-z_][\w']*)`)
+
+// localDeclNames returns the names declared at the top of lines in content.
+func localDeclNames(content string) map[string]bool {
+	names := make(map[string]bool)
+	for _, m := range declNameRe.FindAllStringSubmatch(content, -1) {
+		names[m[1]] = true
+	}
+	return names
+}
+
+// localQualifiedNames returns the names declared in content, qualified by the
+// modules enclosing them as Rocq prints them from within the file. Sections do
+// not qualify names.
+func localQualifiedNames(content string) map[string]bool {
+	names := make(map[string]bool)
+	var walk func(entries []*OutlineEntry, prefix string)
+	walk = func(entries []*OutlineEntry, prefix string) {
+		for _, e := range entries {
+			switch {
+			case e.Kind == "Module":
+				walk(e.Children, prefix+e.Name+".")
+			case e.Kind == "Section":
+				walk(e.Children, prefix)
+			case (theoremKinds[e.Kind] || declKinds[e.Kind] || leafKinds[e.Kind]) && qualidRe.MatchString(e.Name):
+				names[prefix+e.Name] = true
+			}
+		}
+	}
+	walk(BuildOutline(content), "")
+	return names
+}
@@ -0,0 +1,145 @@
+package rocq
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildSearchQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		opts    SearchOptions
+		want    string
+		wantErr bool
+	}{
+		{"plain", "0 + _ = _", SearchOptions{}, "0 + _ = _", false},
+		{"pattern", "_ + 0", SearchOptions{Kind: "pattern"}, "headconcl:(_ + 0)", false},
+		{"rewrite", "_ + 0", SearchOptions{Kind: "rewrite"}, "[headconcl:(_ + 0 = _) | headconcl:(_ = _ + 0)]", false},
+		{"inside", "nat", SearchOptions{Inside: []string{"Nat", "List"}}, "nat inside Nat List", false},
+		{"outside", "nat", SearchOptions{Outside: []string{"Nat"}}, "nat outside Nat", false},
+		{"both scopes", "nat", SearchOptions{Inside: []string{"A"}, Outside: []string{"B"}}, "", true},
+		{"bad kind", "nat", SearchOptions{Kind: "fuzzy"}, "", true},
+		{"qualified scope", "nat", SearchOptions{Inside: []string{"Coq.Arith.PeanoNat"}}, "nat inside Coq.Arith.PeanoNat", false},
+		{"injected inside", "nat", SearchOptions{Inside: []string{"Nat. Print nat"}}, "", true},
+		{"injected outside", "nat", SearchOptions{Outside: []string{"Nat.(Print nat)"}}, "", true},
+		{"empty scope", "nat", SearchOptions{Inside: []string{""}}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildSearchQuery(tt.pattern, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankSearchResults(t *testing.T) {
+	results := []SearchResult{
+		{Name: "Nat.add_0_r", Statement: "forall n : nat, n + 0 = n"},
+		{Name: "plus_n_O", Statement: "forall n : nat, n = n + 0"},
+		{Name: "my_lemma", Statement: "forall n m : nat, n + 0 + m = n + m"},
+		{Name: "Nat.add_0_l", Statement: "forall n : nat, 0 + n = n"},
+	}
+	RankSearchResults(results, localQualifiedNames("Lemma my_lemma : True.\n"), "a")
+	want := []string{"my_lemma", "Nat.add_0_l", "Nat.add_0_r", "plus_n_O"}
+	for i, name := range want {
+		if results[i].Name != name {
+			t.Errorf("position %d: got %s, want %s", i, results[i].Name, name)
+		}
+	}
+}
+
+func TestRankSearchResultsQualified(t *testing.T) {
+	content := `Module M.
+Section S.
+Lemma inner : True.
+Admitted.
+End S.
+End M.
+Lemma add_0_r : True.
+Admitted.
+`
+	results := []SearchResult{
+		{Name: "Nat.add_0_r", Statement: "forall n : nat, n + 0 = n"},
+		{Name: "inner", Statement: "True"},
+		{Name: "M.inner", Statement: "True"},
+		{Name: "Lib.a.add_0_r", Statement: "True"},
+	}
+	RankSearchResults(results, localQualifiedNames(content), "a")
+	// Nat.add_0_r shares its short name with a local lemma but lives elsewhere;
+	// so does "inner", which is only declared inside M.
+	want := []string{"Lib.a.add_0_r", "M.inner", "inner", "Nat.add_0_r"}
+	for i, name := range want {
+		if results[i].Name != name {
+			t.Errorf("position %d: got %s, want %s", i, results[i].Name, name)
+		}
+	}
+}
+
+func TestFormatSearchResults_Paging(t *testing.T) {
+	results := []SearchResult{
+		{Name: "a", Statement: "A"},
+		{Name: "b", Statement: "B"},
+		{Name: "c", Statement: "C"},
+	}
+	got := FormatSearchResults(results, 0, 2)
+	want := `=== Search Results: 1–2 of 3 ===
+a : A
+b : B
+
+(1 more; continue with offset=2)
+`
+	if got != want {
+		t.Errorf("mismatch.\nwant:\n%s\ngot:\n%s", want, got)
+	}
+
+	got = FormatSearchResults(results, 2, 2)
+	want = `=== Search Results: 3–3 of 3 ===
+c : C
+`
+	if got != want {
+		t.Errorf("mismatch.\nwant:\n%s\ngot:\n%s", want, got)
+	}
+
+	got = FormatSearchResults(results, 5, 2)
+	want = "No results at offset 5 (total 3)."
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLocalDeclNames(t *testing.T) {
+	content := `Definition double (n : nat) := n + n.
+#[local] Instance foo : Bar := {}.
+  Program Fixpoint f' n := n.
+(* Lemma commented : True. *)
+Theorem plus_0_n : forall n, 0 + n = n.
+`
+	got := localDeclNames(content)
+	for _, name := range []string{"double", "foo", "f'", "plus_0_n"} {
+		if !got[name] {
+			t.Errorf("missing %s in %v", name, got)
+		}
+	}
+	if got["commented"] {
+		t.Errorf("commented-out declaration should not be found")
+	}
+}
+
+func TestSearchResultsBeforeReply(t *testing.T) {
+	sm, _ := newStuckStateManager()
+	defer sm.Shutdown()
+	path := writeTestFile(t, t.TempDir(), "a.v", "Definition n := 1.\n")
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatal(err)
+	}
+	res := resultText(toolResult(DoSearch(sm, path, "True", nil, SearchOptions{Limit: 1})))
+	if !strings.Contains(res, "of 300 ===") {
+		t.Errorf("search: %q", res)
+	}
+}
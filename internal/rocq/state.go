@@ -4,11 +4,11 @@ package rocq
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
 	"sync"
+	"time"
 )
 
 // DocState tracks per-document state.
@@ -18,92 +18,365 @@ type DocState struct {
 	Content     string
 	Diagnostics []Diagnostic
 	ProofView   *ProofView
+	ExecPos     Position // where execution last stopped; default context for queries
+	Checkpoints map[string]*Checkpoint
 
 	// Channels for bridging async notifications to sync tool calls.
 	ProofViewCh  chan *ProofView
 	DiagnosticCh chan []Diagnostic
 	CursorCh     chan Position
+	HighlightCh  chan bool // from updateHighlights: whether sentences are still pending
+
+	ops      chan struct{} // operation queue, one slot; see StateManager.acquireDoc
+	closed   bool          // set under StateManager.Mu when the document is closed
+	users    int           // operations queued or running on the document
+	lastUsed time.Time     // when the last operation started or finished
+
+	// interrupt is closed by DoInterrupt to stop the proof command waiting on
+	// vsrocq; non-nil only while one waits (see collectResultsFull).
+	interrupt    chan struct{}
+	interrupting bool         // set while DoInterrupt signals vsrocqtop
+	breach       *limitBreach // set with interrupt when vsrocqtop exceeds a limit
+	processed    Position     // end of what vsrocq last reported executed
 }
 
 // StateManager manages per-document state and the vsrocq client.
+//
+// Mu guards Docs and the fields of every DocState, and is only held briefly:
+// never while sending to vsrocq, whose notification handlers also take it.
+// Operations on a document run one at a time in its queue (acquireDoc);
+// operations that execute proofs are also serialized across documents
+// (acquireProver).
 type StateManager struct {
 	Client *VsrocqClient
 	Docs   map[string]*DocState // keyed by URI
 	Mu     sync.Mutex
 	args   []string // extra args for vsrocqtop
 
-	// Search result channels, keyed by search ID.
-	searchHandlers   map[string]chan SearchResult
+	// prover is a one-slot semaphore held by the one operation waiting for
+	// proofView notifications, which carry no URI to attribute them by.
+	prover chan struct{}
+	// proverJob is the background job holding prover, if any (see
+	// acquireProver), and jobHolds is closed while it does. Guarded by Mu.
+	proverJob *job
+	jobHolds  chan struct{}
+	clientMu  sync.Mutex // serializes starting the client
+	stopped   bool       // set by Shutdown; the client is not restarted after it
+
+	lifecycle Lifecycle
+	limits    Limits
+	sandboxed bool     // run the prover under SandboxProver's restrictions
+	writable  []string // canonical directories the sandboxed prover may write
+
+	// Names of the checkpoints lost when evictIdle closed a document, by URI.
+	lostCheckpoints map[string][]string
+
+	roots       []string // workspace roots from the MCP client (see SetRoots)
+	allowed     []string // canonical roots file arguments must lie under (see SetAllowedRoots)
+	clientRoots []string // workspace folders vsrocq was last told about
+
+	// URI of the document that last ran a proof command; vsrocq's proofView
+	// notifications carry no URI and are attributed to it.
+	active string
+
+	// startClient starts the prover; replaced to replay a recorded transcript.
+	startClient func(args []string, tap tapFunc) (*VsrocqClient, error)
+	recorder    *Recorder // if non-nil, LSP traffic is recorded
+
+	// Listener for document lifecycle and state changes (see OnDocEvent).
+	onDocEvent func(path, kind string)
+
+	// Background jobs, keyed by job ID (see jobs.go). Guarded by Mu.
+	jobs   map[string]*job
+	jobSeq int
+
+	// Search result sinks, keyed by search ID.
+	searchHandlers   map[string]*searchSink
 	searchHandlersMu sync.Mutex
 }
 
+// Document event kinds reported to the OnDocEvent listener.
+const (
+	DocOpened             = "opened"
+	DocClosed             = "closed"
+	DocGoalsChanged       = "goals"
+	DocDiagnosticsChanged = "diagnostics"
+)
+
 func NewStateManager(args []string) *StateManager {
-	return &StateManager{
-		Docs:           make(map[string]*DocState),
-		args:           args,
-		searchHandlers: make(map[string]chan SearchResult),
+	sm := &StateManager{
+		Docs:            make(map[string]*DocState),
+		args:            args,
+		searchHandlers:  make(map[string]*searchSink),
+		jobs:            make(map[string]*job),
+		lostCheckpoints: make(map[string][]string),
+		prover:          make(chan struct{}, 1),
+		jobHolds:        make(chan struct{}),
 	}
+	sm.startClient = sm.startProver
+	return sm
+}
+
+// OnDocEvent registers fn to be called when a document is opened or closed,
+// or its goals or diagnostics change. fn is called without sm.Mu held; goal
+// and diagnostic changes are reported from the vsrocq read loop, so fn must
+// not block.
+func (sm *StateManager) OnDocEvent(fn func(path, kind string)) {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	sm.onDocEvent = fn
 }
 
-// ensureClient lazily starts vsrocqtop.
+// emit reports a document event to the listener, if any.
+// Caller must not hold sm.Mu.
+func (sm *StateManager) emit(uri, kind string) {
+	sm.Mu.Lock()
+	fn := sm.onDocEvent
+	sm.Mu.Unlock()
+	if fn != nil {
+		fn(URIPath(uri), kind)
+	}
+}
+
+// ProofState returns the last known proof view and diagnostics of an open document.
+func (sm *StateManager) ProofState(path string) (*ProofView, []Diagnostic, error) {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	doc, err := sm.GetDoc(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc.ProofView, doc.Diagnostics, nil
+}
+
+// acquireDoc looks up an open document and waits for its turn in the
+// document's operation queue, so that operations on one file run one at a
+// time while operations on different files proceed concurrently. With
+// Lifecycle.AutoOpen, a document that is not open is opened first. The caller
+// must call release when done, and must not hold sm.Mu.
+func (sm *StateManager) acquireDoc(path string) (*DocState, func(), error) {
+	sm.Mu.Lock()
+	auto := sm.lifecycle.AutoOpen
+	sm.Mu.Unlock()
+	return sm.lockDoc(path, auto)
+}
+
+// lockDoc is acquireDoc with auto-opening controlled by autoOpen.
+func (sm *StateManager) lockDoc(path string, autoOpen bool) (*DocState, func(), error) {
+	return sm.lockDocUnless(path, autoOpen, nil)
+}
+
+// errCancelledWaiting is returned by lockDocUnless and acquireProverUnless
+// when cancel is closed before the operation's turn comes.
+var errCancelledWaiting = errors.New("cancelled while waiting for its turn")
+
+// lockDocUnless is lockDoc, except that it gives up waiting for the
+// document's turn once cancel is closed.
+func (sm *StateManager) lockDocUnless(path string, autoOpen bool, cancel <-chan struct{}) (*DocState, func(), error) {
+	// A document closed while we wait for it is reopened once.
+	for range 2 {
+		sm.Mu.Lock()
+		doc, err := sm.GetDoc(path)
+		if err == nil {
+			doc.users++
+		}
+		sm.Mu.Unlock()
+		if err != nil {
+			if !autoOpen {
+				return nil, nil, err
+			}
+			if _, err := sm.EnsureOpen(path); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		select {
+		case doc.ops <- struct{}{}:
+		case <-cancel:
+			sm.Mu.Lock()
+			doc.users--
+			sm.Mu.Unlock()
+			return nil, nil, errCancelledWaiting
+		}
+		sm.Mu.Lock()
+		closed := doc.closed
+		if closed {
+			doc.users--
+		} else {
+			doc.lastUsed = time.Now()
+		}
+		sm.Mu.Unlock()
+		if !closed {
+			return doc, func() {
+				sm.Mu.Lock()
+				doc.users--
+				doc.lastUsed = time.Now()
+				sm.Mu.Unlock()
+				<-doc.ops
+			}, nil
+		}
+		<-doc.ops
+		if !autoOpen {
+			break
+		}
+	}
+	return nil, nil, fmt.Errorf("document not open: %s", path)
+}
+
+// acquireProver is acquireDoc for operations that execute proofs and wait for
+// proofView notifications; it additionally waits until no such operation is
+// running on any other document. Rather than wait behind a background job,
+// which may run for up to JobTimeout, it fails at once saying which job is
+// running.
+func (sm *StateManager) acquireProver(path string) (*DocState, func(), error) {
+	sm.Mu.Lock()
+	holds := sm.jobHolds
+	sm.Mu.Unlock()
+	select {
+	case <-holds:
+		return nil, nil, sm.busyError()
+	default:
+	}
+
+	doc, release, err := sm.acquireDoc(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	select {
+	case sm.prover <- struct{}{}:
+	case <-holds:
+		release()
+		return nil, nil, sm.busyError()
+	}
+	return doc, func() {
+		<-sm.prover
+		release()
+	}, nil
+}
+
+// busyError reports the background job holding the prover.
+func (sm *StateManager) busyError() error {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	if j := sm.proverJob; j != nil {
+		return fmt.Errorf("vsrocq is busy with %s (%s %s); wait for it with rocq_job_result or stop it with rocq_job_cancel",
+			j.id, j.kind, j.file)
+	}
+	return errors.New("vsrocq is busy with a background job")
+}
+
+// acquireProverUnless is acquireProver for background job j: it waits
+// behind other operations, including other jobs, until cancel is closed.
+// While j holds the prover, acquireProver fails instead of waiting for it.
+func (sm *StateManager) acquireProverUnless(path string, j *job, cancel <-chan struct{}) (*DocState, func(), error) {
+	sm.Mu.Lock()
+	auto := sm.lifecycle.AutoOpen
+	sm.Mu.Unlock()
+	doc, release, err := sm.lockDocUnless(path, auto, cancel)
+	if err != nil {
+		return nil, nil, err
+	}
+	select {
+	case sm.prover <- struct{}{}:
+	case <-cancel:
+		release()
+		return nil, nil, errCancelledWaiting
+	}
+	sm.Mu.Lock()
+	sm.proverJob = j
+	close(sm.jobHolds)
+	sm.Mu.Unlock()
+	return doc, func() {
+		sm.Mu.Lock()
+		sm.proverJob = nil
+		sm.jobHolds = make(chan struct{})
+		sm.Mu.Unlock()
+		<-sm.prover
+		release()
+	}, nil
+}
+
+// ensureClient lazily starts vsrocqtop. Caller must not hold sm.Mu.
 func (sm *StateManager) ensureClient() error {
+	sm.clientMu.Lock()
+	defer sm.clientMu.Unlock()
+	return sm.startClientLocked()
+}
+
+// startClientLocked starts vsrocqtop if it is not running.
+// Caller must hold sm.clientMu but not sm.Mu.
+func (sm *StateManager) startClientLocked() error {
 	if sm.Client != nil {
 		return nil
 	}
-	client, err := newVsrocqClient(sm.args)
+
+	sm.Mu.Lock()
+	start, args := sm.startClient, sm.args
+	roots := workspaceRoots(sm.roots)
+	var tap tapFunc
+	if sm.recorder != nil {
+		tap = sm.recorder.recordLSP
+	}
+	sm.Mu.Unlock()
+
+	client, err := start(args, tap)
 	if err != nil {
 		return err
 	}
-	sm.Client = client
-
 	// Register notification handlers.
 	client.onNotification("textDocument/publishDiagnostics", sm.handleDiagnostics)
 	client.onNotification("prover/proofView", sm.handleProofView)
 	client.onNotification("prover/searchResult", sm.handleSearchResult)
-	client.onNotification("prover/updateHighlights", func(params json.RawMessage) {})
+	client.onNotification("prover/updateHighlights", sm.handleHighlights)
 	client.onNotification("prover/moveCursor", sm.handleMoveCursor)
 	client.onNotification("prover/blockOnError", func(params json.RawMessage) {})
 	client.onNotification("prover/debugMessage", func(params json.RawMessage) {
 		log.Printf("vsrocq debug: %s", string(params))
 	})
 
-	// Initialize with current working directory.
-	cwd, _ := os.Getwd()
-	rootURI := "file://" + cwd
-	if err := client.initialize(rootURI); err != nil {
+	// Initialize with the workspace roots, or the working directory if none.
+	if err := client.initialize(roots); err != nil {
+		client.kill()
 		return err
 	}
 
+	sm.Mu.Lock()
+	sm.Client = client
+	sm.clientRoots = roots
+	sm.Mu.Unlock()
 	return nil
 }
 
-func FileURI(path string) string {
-	abs, err := filepath.Abs(path)
-	if err != nil {
-		abs = path
-	}
-	return "file://" + abs
-}
+// ErrAlreadyOpen is returned by OpenDoc for a document that is already open.
+var ErrAlreadyOpen = errors.New("document already open")
 
 // OpenDoc opens a .v file in vsrocq.
 func (sm *StateManager) OpenDoc(path string) error {
-	sm.Mu.Lock()
-	defer sm.Mu.Unlock()
-
-	if err := sm.ensureClient(); err != nil {
+	if err := sm.openDoc(path); err != nil {
 		return err
 	}
+	sm.emit(FileURI(path), DocOpened)
+	sm.evictIdle(FileURI(path))
+	return nil
+}
 
-	uri := FileURI(path)
-	if _, exists := sm.Docs[uri]; exists {
-		return fmt.Errorf("document already open: %s", path)
+func (sm *StateManager) openDoc(path string) error {
+	if err := sm.ensureClient(); err != nil {
+		return err
 	}
 
-	content, err := os.ReadFile(path)
+	content, err := sm.readFile(path)
 	if err != nil {
-		return fmt.Errorf("read file: %w", err)
+		return err
 	}
 
+	sm.Mu.Lock()
+	uri := FileURI(path)
+	if _, exists := sm.Docs[uri]; exists {
+		sm.Mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrAlreadyOpen, path)
+	}
 	doc := &DocState{
 		URI:          uri,
 		Version:      1,
@@ -111,8 +384,15 @@ func (sm *StateManager) OpenDoc(path string) error {
 		ProofViewCh:  make(chan *ProofView, 16),
 		DiagnosticCh: make(chan []Diagnostic, 16),
 		CursorCh:     make(chan Position, 16),
+		HighlightCh:  make(chan bool, 16),
+		lastUsed:     time.Now(),
+		ops:          make(chan struct{}, 1),
 	}
 	sm.Docs[uri] = doc
+	// Hold the document's queue until vsrocq has been told about it.
+	doc.ops <- struct{}{}
+	defer func() { <-doc.ops }()
+	sm.Mu.Unlock()
 
 	params := map[string]any{
 		"textDocument": map[string]any{
@@ -127,51 +407,68 @@ func (sm *StateManager) OpenDoc(path string) error {
 
 // CloseDoc closes a document in vsrocq.
 func (sm *StateManager) CloseDoc(path string) error {
-	sm.Mu.Lock()
-	defer sm.Mu.Unlock()
+	closed, err := sm.closeDoc(path)
+	if closed {
+		sm.emit(FileURI(path), DocClosed)
+	}
+	return err
+}
 
-	uri := FileURI(path)
-	doc, ok := sm.Docs[uri]
-	if !ok {
-		return fmt.Errorf("document not open: %s", path)
+func (sm *StateManager) closeDoc(path string) (bool, error) {
+	doc, release, err := sm.lockDoc(path, false)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	sm.Mu.Lock()
+	doc.closed = true
+	delete(sm.Docs, doc.URI)
+	if sm.active == doc.URI {
+		sm.active = ""
 	}
+	sm.Mu.Unlock()
 
 	params := map[string]any{
 		"textDocument": map[string]any{
 			"uri": doc.URI,
 		},
 	}
-	err := sm.Client.Notify("textDocument/didClose", params)
-	delete(sm.Docs, uri)
-	return err
+	return true, sm.Client.Notify("textDocument/didClose", params)
 }
 
 // SyncDoc re-reads a file from disk and sends didChange.
 func (sm *StateManager) SyncDoc(path string) error {
-	sm.Mu.Lock()
-	defer sm.Mu.Unlock()
-
-	uri := FileURI(path)
-	doc, ok := sm.Docs[uri]
-	if !ok {
-		return fmt.Errorf("document not open: %s", path)
+	doc, release, err := sm.acquireDoc(path)
+	if err != nil {
+		return err
 	}
+	defer release()
 
-	content, err := os.ReadFile(path)
+	content, err := sm.readFile(path)
 	if err != nil {
-		return fmt.Errorf("read file: %w", err)
+		return err
 	}
 
+	return sm.changeDoc(doc, string(content))
+}
+
+// changeDoc replaces a document's content and sends didChange.
+// Caller must hold the document's turn (see acquireDoc) but not sm.Mu.
+func (sm *StateManager) changeDoc(doc *DocState, content string) error {
+	sm.Mu.Lock()
 	doc.Version++
-	doc.Content = string(content)
+	doc.Content = content
+	version := doc.Version
+	sm.Mu.Unlock()
 
 	params := map[string]any{
 		"textDocument": map[string]any{
 			"uri":     doc.URI,
-			"version": doc.Version,
+			"version": version,
 		},
 		"contentChanges": []map[string]any{
-			{"text": doc.Content},
+			{"text": content},
 		},
 	}
 	return sm.Client.Notify("textDocument/didChange", params)
@@ -199,7 +496,7 @@ func (sm *StateManager) handleDiagnostics(params json.RawMessage) {
 	}
 
 	sm.Mu.Lock()
-	doc, ok := sm.Docs[p.URI]
+	doc, ok := sm.lookupURI(p.URI)
 	if ok {
 		doc.Diagnostics = p.Diagnostics
 	}
@@ -211,6 +508,7 @@ func (sm *StateManager) handleDiagnostics(params json.RawMessage) {
 		case doc.DiagnosticCh <- p.Diagnostics:
 		default:
 		}
+		sm.emit(doc.URI, DocDiagnosticsChanged)
 	}
 }
 
@@ -225,13 +523,54 @@ func (sm *StateManager) handleProofView(params json.RawMessage) {
 	// proofView doesn't include URI directly — deliver to all docs with waiting channels.
 	// In practice, there's typically only one active proof at a time.
 	sm.Mu.Lock()
-	defer sm.Mu.Unlock()
 	for _, doc := range sm.Docs {
 		select {
 		case doc.ProofViewCh <- pv:
 		default:
 		}
 	}
+	active, ok := sm.Docs[sm.active]
+	if ok {
+		active.ProofView = pv
+	}
+	sm.Mu.Unlock()
+
+	if ok {
+		sm.emit(active.URI, DocGoalsChanged)
+	}
+}
+
+// handleHighlights processes prover/updateHighlights notifications, recording
+// how far each document has been executed and telling a waiting proof command
+// whether sentences are still pending.
+func (sm *StateManager) handleHighlights(params json.RawMessage) {
+	var p struct {
+		URI        string  `json:"uri"`
+		Prepared   []Range `json:"preparedRange"`
+		Processing []Range `json:"processingRange"`
+		Processed  []Range `json:"processedRange"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		log.Printf("parse updateHighlights: %v", err)
+		return
+	}
+
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	doc, ok := sm.lookupURI(p.URI)
+	if !ok {
+		return
+	}
+	doc.processed = Position{}
+	for _, r := range p.Processed {
+		if e := r.End; e.Line > doc.processed.Line || e.Line == doc.processed.Line && e.Character > doc.processed.Character {
+			doc.processed = e
+		}
+	}
+	select {
+	case doc.HighlightCh <- len(p.Prepared) > 0 || len(p.Processing) > 0:
+	default:
+	}
 }
 
 // handleMoveCursor processes prover/moveCursor notifications.
@@ -250,7 +589,8 @@ func (sm *StateManager) handleMoveCursor(params json.RawMessage) {
 	defer sm.Mu.Unlock()
 
 	if p.URI != "" {
-		if doc, ok := sm.Docs[p.URI]; ok {
+		if doc, ok := sm.lookupURI(p.URI); ok {
+			doc.ExecPos = pos
 			select {
 			case doc.CursorCh <- pos:
 			default:
@@ -261,6 +601,7 @@ func (sm *StateManager) handleMoveCursor(params json.RawMessage) {
 
 	// No URI — broadcast to all docs (like proofView).
 	for _, doc := range sm.Docs {
+		doc.ExecPos = pos
 		select {
 		case doc.CursorCh <- pos:
 		default:
@@ -268,18 +609,28 @@ func (sm *StateManager) handleMoveCursor(params json.RawMessage) {
 	}
 }
 
+// searchSink delivers results for one in-flight search to its collector.
+type searchSink struct {
+	ch   chan SearchResult
+	done chan struct{} // closed when the collector stops listening
+}
+
 // RegisterSearchHandler registers a channel to receive search results for a given ID.
+// Results are delivered without dropping until UnregisterSearchHandler is called.
 func (sm *StateManager) RegisterSearchHandler(id string, ch chan SearchResult) {
 	sm.searchHandlersMu.Lock()
 	defer sm.searchHandlersMu.Unlock()
-	sm.searchHandlers[id] = ch
+	sm.searchHandlers[id] = &searchSink{ch: ch, done: make(chan struct{})}
 }
 
 // UnregisterSearchHandler removes a search result channel.
 func (sm *StateManager) UnregisterSearchHandler(id string) {
 	sm.searchHandlersMu.Lock()
 	defer sm.searchHandlersMu.Unlock()
-	delete(sm.searchHandlers, id)
+	if sink, ok := sm.searchHandlers[id]; ok {
+		close(sink.done)
+		delete(sm.searchHandlers, id)
+	}
 }
 
 // handleSearchResult processes prover/searchResult notifications.
@@ -301,19 +652,24 @@ func (sm *StateManager) handleSearchResult(params json.RawMessage) {
 	}
 
 	sm.searchHandlersMu.Lock()
-	ch, ok := sm.searchHandlers[raw.ID]
+	sink, ok := sm.searchHandlers[raw.ID]
 	sm.searchHandlersMu.Unlock()
 
 	if ok {
 		select {
-		case ch <- result:
-		default:
+		case sink.ch <- result:
+		case <-sink.done:
 		}
 	}
 }
 
 // Shutdown cleans up the vsrocq client.
 func (sm *StateManager) Shutdown() error {
+	sm.clientMu.Lock()
+	defer sm.clientMu.Unlock()
+	sm.Mu.Lock()
+	sm.stopped = true
+	sm.Mu.Unlock()
 	if sm.Client == nil {
 		return nil
 	}
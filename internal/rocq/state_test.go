@@ -0,0 +1,139 @@
+package rocq
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// echoProver is a minimal stand-in for vsrocqtop: it answers every request
+// with a null result and every proof command with an empty proofView followed
+// by a diagnostic naming the document and version it was run on.
+func echoProver(codec *lspCodec) {
+	for {
+		msg, err := codec.decode()
+		if err != nil {
+			return
+		}
+		if msg.Method == nil {
+			continue
+		}
+		if msg.ID != nil {
+			codec.encode(map[string]any{"jsonrpc": "2.0", "id": *msg.ID, "result": nil})
+			continue
+		}
+		switch *msg.Method {
+		case "prover/interpretToPoint", "prover/interpretToEnd", "prover/stepForward", "prover/stepBackward":
+		default:
+			continue
+		}
+		var p struct {
+			TextDocument struct {
+				URI     string `json:"uri"`
+				Version int    `json:"version"`
+			} `json:"textDocument"`
+		}
+		json.Unmarshal(msg.Params, &p)
+		codec.encode(map[string]any{"jsonrpc": "2.0", "method": "prover/proofView",
+			"params": map[string]any{"proof": map[string]any{"goals": []any{}}}})
+		codec.encode(map[string]any{"jsonrpc": "2.0", "method": "textDocument/publishDiagnostics",
+			"params": map[string]any{"uri": p.TextDocument.URI, "diagnostics": []any{map[string]any{
+				"range":    map[string]any{"start": map[string]any{"line": 0, "character": 0}, "end": map[string]any{"line": 0, "character": 1}},
+				"message":  fmt.Sprintf("ran %s v%d", URIPath(p.TextDocument.URI), p.TextDocument.Version),
+				"severity": 3,
+			}}}})
+	}
+}
+
+func newEchoStateManager() *StateManager {
+	sm := NewStateManager(nil)
+	sm.startClient = func(_ []string, tap tapFunc) (*VsrocqClient, error) {
+		toProver, fromClient := io.Pipe()
+		fromProver, toClient := io.Pipe()
+		go echoProver(newLSPCodec(toProver, toClient))
+		return newClientConn(fromProver, fromClient, tap), nil
+	}
+	return sm
+}
+
+func TestConcurrentToolCalls(t *testing.T) {
+	sm := newEchoStateManager()
+	defer sm.Shutdown()
+
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.v", "b.v", "c.v"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("Lemma foo : True.\nProof. exact I. Qed.\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := sm.OpenDoc(path); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 100)
+	for _, file := range files {
+		for i := range 4 {
+			wg.Go(func() {
+				var res string
+				switch i {
+				case 0:
+					res = resultText(toolResult(DoCheck(sm, file, 1, 5)))
+				case 1:
+					res = resultText(toolResult(DoCheckAll(sm, file)))
+				case 2:
+					if err := sm.SyncDoc(file); err != nil {
+						errs <- err.Error()
+					}
+					res = resultText(toolResult(DoStep(sm, file, "prover/stepForward")))
+				case 3:
+					toolResult(DoCheckpoint(sm, file, "cp"))
+					toolResult(DoQuery(sm, file, "prover/check", "foo", nil))
+					res = resultText(toolResult(DoRestore(sm, file, "cp")))
+				}
+				// Each proof command must see only its own document's results.
+				if !strings.Contains(res, "ran "+file+" v") {
+					errs <- fmt.Sprintf("%s: result for another document or none:\n%s", file, res)
+				}
+			})
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Error(e)
+	}
+}
+
+func TestCloseWaitsForOperation(t *testing.T) {
+	sm := newEchoStateManager()
+	defer sm.Shutdown()
+
+	path := filepath.Join(t.TempDir(), "a.v")
+	if err := os.WriteFile(path, []byte("Lemma foo : True.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.OpenDoc(path); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan string)
+	go func() { done <- resultText(toolResult(DoCheckAll(sm, path))) }()
+	if err := sm.CloseDoc(path); err != nil {
+		t.Fatal(err)
+	}
+	if res := <-done; !strings.Contains(res, "ran "+path) && !strings.Contains(res, "document not open") {
+		t.Errorf("unexpected result: %s", res)
+	}
+	if res := resultText(toolResult(DoCheck(sm, path, 0, 0))); !strings.Contains(res, "document not open") {
+		t.Errorf("check after close = %q", res)
+	}
+}
@@ -6,6 +6,9 @@ package rocq
 type Goal struct {
 	ID   string
 	Text string // pre-rendered: hypotheses + separator + conclusion
+
+	Hypotheses []string // rendered hypotheses, as in Text
+	Conclusion string   // rendered conclusion, as in Text
 }
 
 // ProofView stores all focused goals as pre-rendered text, plus metadata.
@@ -57,3 +60,26 @@ type ProofStep struct {
 	Tactic string `json:"tactic"`
 	Range  Range  `json:"range"`
 }
+
+// SentenceState is one sentence of vsrocq's internal document state, as
+// parsed from the prover/documentState dump.
+type SentenceState struct {
+	ID     string
+	Text   string
+	Start  int // byte offset into the document, -1 if unknown
+	End    int // byte offset into the document, -1 if unknown
+	Status string
+}
+
+// Location is an LSP location: a range in a file identified by URI.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// Completion is a candidate identifier returned by rocq_complete.
+type Completion struct {
+	Name string
+	Kind string // e.g. "constant", "constructor", "module"; "lemma" for search fallbacks
+	Type string
+}
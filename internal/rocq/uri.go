@@ -0,0 +1,79 @@
+package rocq
+
+// uri.go — conversion between filesystem paths and file:// URIs (RFC 8089).
+//
+// Documents are keyed by the URI of their canonical path: absolute, cleaned
+// and with symlinks resolved, so that every spelling of a path names the same
+// document. URIs from vsrocq are decoded and re-canonicalized before lookup,
+// since they may be encoded differently from the ones we sent.
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// CanonicalPath returns the absolute, cleaned form of path with symlinks
+// resolved. For a file that does not exist, symlinks in its directory are
+// still resolved.
+func CanonicalPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = filepath.Clean(path)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs
+}
+
+// FileURI returns the file:// URI of path's canonical form, percent-encoding
+// characters outside the unreserved path set (spaces, non-ASCII, '%', '?', '#').
+func FileURI(path string) string {
+	p := filepath.ToSlash(CanonicalPath(path))
+	if !strings.HasPrefix(p, "/") {
+		// Windows drive paths: file:///C:/dir
+		p = "/" + p
+	}
+	u := url.URL{Scheme: "file", Path: p}
+	return u.String()
+}
+
+// URIPath converts a file:// URI back to a filesystem path, decoding
+// percent-escapes. A URI that does not parse is returned without its scheme.
+func URIPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return strings.TrimPrefix(uri, "file://")
+	}
+	p := u.Path
+	if len(p) >= 3 && p[0] == '/' && p[2] == ':' {
+		p = p[1:] // "/C:/dir" → "C:/dir"
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		p = "//" + u.Host + p // UNC path
+	}
+	return filepath.FromSlash(p)
+}
+
+// normalizeURI returns the canonical URI for a file:// URI received from vsrocq.
+func normalizeURI(uri string) string {
+	if !strings.HasPrefix(uri, "file:") {
+		return uri
+	}
+	return FileURI(URIPath(uri))
+}
+
+// lookupURI finds the open document for a URI received from vsrocq, which may
+// be encoded differently from the one the document was opened with.
+// Caller must hold sm.Mu.
+func (sm *StateManager) lookupURI(uri string) (*DocState, bool) {
+	if doc, ok := sm.Docs[uri]; ok {
+		return doc, true
+	}
+	doc, ok := sm.Docs[normalizeURI(uri)]
+	return doc, ok
+}
@@ -0,0 +1,108 @@
+package rocq
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileURI(t *testing.T) {
+	tests := []struct{ path, uri string }{
+		{"/home/u/proof.v", "file:///home/u/proof.v"},
+		{"/home/u/my proofs/a b.v", "file:///home/u/my%20proofs/a%20b.v"},
+		{"/home/u/théorème.v", "file:///home/u/th%C3%A9or%C3%A8me.v"},
+		{"/home/u/100%/a#1?.v", "file:///home/u/100%25/a%231%3F.v"},
+		{"/home/u/./x/../a.v", "file:///home/u/a.v"},
+	}
+	for _, tt := range tests {
+		if got := FileURI(tt.path); got != tt.uri {
+			t.Errorf("FileURI(%q) = %q, want %q", tt.path, got, tt.uri)
+		}
+	}
+}
+
+func TestURIPath(t *testing.T) {
+	tests := []struct{ uri, path string }{
+		{"file:///home/u/proof.v", "/home/u/proof.v"},
+		{"file:///home/u/my%20proofs/a%20b.v", "/home/u/my proofs/a b.v"},
+		{"file:///home/u/th%c3%a9or%c3%a8me.v", "/home/u/théorème.v"},
+		{"file:///home/u/100%25/a%231%3F.v", "/home/u/100%/a#1?.v"},
+		{"file://localhost/home/u/a.v", "/home/u/a.v"},
+		{"file:///home/u/a%3Ab.v", "/home/u/a:b.v"},
+		{"/not/a/uri.v", "/not/a/uri.v"},
+	}
+	for _, tt := range tests {
+		if got := URIPath(tt.uri); got != tt.path {
+			t.Errorf("URIPath(%q) = %q, want %q", tt.uri, got, tt.path)
+		}
+	}
+}
+
+func TestCanonicalPathSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "real dir")
+	if err := os.Mkdir(real, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, real, "a.v", "")
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := filepath.EvalSymlinks(filepath.Join(real, "a.v"))
+	wantDir, _ := filepath.EvalSymlinks(real)
+
+	tests := []struct{ path, want string }{
+		{filepath.Join(link, "a.v"), want},
+		{filepath.Join(real, "a.v"), want},
+		{filepath.Join(link, "new.v"), filepath.Join(wantDir, "new.v")}, // does not exist yet
+	}
+	for _, tt := range tests {
+		if got := CanonicalPath(tt.path); got != tt.want {
+			t.Errorf("CanonicalPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+// Diagnostics must reach a document opened through a symlinked path with
+// spaces, even when vsrocq spells the URI differently.
+func TestDiagnosticsURIMatching(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "my proofs")
+	if err := os.Mkdir(real, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, real, "é.v", "")
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Fatal(err)
+	}
+	opened := filepath.Join(link, "é.v")
+
+	sm := newEchoStateManager()
+	defer sm.Shutdown()
+	if err := sm.OpenDoc(opened); err != nil {
+		t.Fatal(err)
+	}
+	canonical := CanonicalPath(opened)
+
+	for _, uri := range []string{
+		FileURI(opened),
+		"file://" + filepath.ToSlash(canonical), // unencoded
+		"file://localhost" + filepath.ToSlash(canonical),
+	} {
+		params, _ := json.Marshal(map[string]any{
+			"uri":         uri,
+			"diagnostics": []Diagnostic{{Message: uri, Severity: 1}},
+		})
+		sm.handleDiagnostics(params)
+		_, diags, err := sm.ProofState(opened)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(diags) != 1 || diags[0].Message != uri {
+			t.Errorf("diagnostics for %q not delivered: %+v", uri, diags)
+		}
+	}
+}
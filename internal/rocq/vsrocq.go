@@ -4,7 +4,9 @@ package rocq
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -17,18 +19,40 @@ type VsrocqClient struct {
 	codec *lspCodec
 
 	// Pending request responses, keyed by ID.
-	pending   map[int64]chan *rawMessage
+	pending   map[int64]pendingRequest
 	pendingMu sync.Mutex
 
 	// Notification handlers.
 	handlers   map[string]func(json.RawMessage)
 	handlersMu sync.RWMutex
+
+	done chan struct{} // closed when the read loop stops
+
+	// For a subprocess: closed once it has exited, with Wait's result, and
+	// the end of its stderr.
+	exited  chan struct{}
+	waitErr error
+	stderr  *tailBuffer
+
+	// signal sends SIGINT to the subprocess; nil for a client without one.
+	signal func() error
 }
 
-func newVsrocqClient(extraArgs []string) (*VsrocqClient, error) {
-	args := append([]string{}, extraArgs...)
-	cmd := exec.Command("vsrocqtop", args...)
-	cmd.Stderr = os.Stderr
+// errConnClosed is returned by Request when vsrocqtop's output ends before it answers.
+var errConnClosed = errors.New("vsrocqtop connection closed")
+
+// newVsrocqClient starts vsrocqtop with extraArgs. If tap is non-nil, it sees
+// every LSP message exchanged with the subprocess.
+func newVsrocqClient(extraArgs []string, tap tapFunc) (*VsrocqClient, error) {
+	return startVsrocq(append([]string{"vsrocqtop"}, extraArgs...), tap)
+}
+
+// startVsrocq runs argv, which is vsrocqtop or a wrapper that executes it,
+// and connects to it over stdio.
+func startVsrocq(argv []string, tap tapFunc) (*VsrocqClient, error) {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	stderr := &tailBuffer{max: 4096}
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -43,19 +67,38 @@ func newVsrocqClient(extraArgs []string) (*VsrocqClient, error) {
 		return nil, fmt.Errorf("start vsrocqtop: %w", err)
 	}
 
+	client := newClientConn(stdout, stdin, tap)
+	client.cmd = cmd
+	client.stderr = stderr
+	client.signal = func() error { return cmd.Process.Signal(os.Interrupt) }
+	client.exited = make(chan struct{})
+	go func() {
+		// Wait closes stdout, so only once the read loop has drained it.
+		<-client.done
+		client.waitErr = cmd.Wait()
+		close(client.exited)
+	}()
+	return client, nil
+}
+
+// newClientConn creates a client speaking LSP over r and w and starts its read loop.
+func newClientConn(r io.Reader, w io.Writer, tap tapFunc) *VsrocqClient {
+	codec := newLSPCodec(r, w)
+	codec.tap = tap
 	client := &VsrocqClient{
-		cmd:      cmd,
-		codec:    newLSPCodec(stdout, stdin),
-		pending:  make(map[int64]chan *rawMessage),
+		codec:    codec,
+		pending:  make(map[int64]pendingRequest),
 		handlers: make(map[string]func(json.RawMessage)),
+		done:     make(chan struct{}),
 	}
 
 	go client.readLoop()
-	return client, nil
+	return client
 }
 
 // readLoop reads messages from vsrocqtop and dispatches them.
 func (c *VsrocqClient) readLoop() {
+	defer close(c.done)
 	for {
 		msg, err := c.codec.decode()
 		if err != nil {
@@ -66,13 +109,13 @@ func (c *VsrocqClient) readLoop() {
 		if msg.ID != nil && msg.Method == nil {
 			// Response to a request.
 			c.pendingMu.Lock()
-			ch, ok := c.pending[*msg.ID]
+			req, ok := c.pending[*msg.ID]
 			if ok {
 				delete(c.pending, *msg.ID)
 			}
 			c.pendingMu.Unlock()
 			if ok {
-				ch <- msg
+				req.ch <- msg
 			}
 		} else if msg.ID != nil && msg.Method != nil {
 			// Server→client request (e.g. workspace/configuration).
@@ -131,24 +174,20 @@ func (c *VsrocqClient) handleServerRequest(id int64, method string, params json.
 
 // Request sends an LSP request and waits for the response.
 func (c *VsrocqClient) Request(method string, params any) (json.RawMessage, error) {
-	ch := make(chan *rawMessage, 1)
-
-	id := c.codec.nextID.Add(1) - 1
-	c.pendingMu.Lock()
-	c.pending[id] = ch
-	c.pendingMu.Unlock()
-
 	var rawParams json.RawMessage
 	if params != nil {
 		var err error
 		rawParams, err = json.Marshal(params)
 		if err != nil {
-			c.pendingMu.Lock()
-			delete(c.pending, id)
-			c.pendingMu.Unlock()
 			return nil, err
 		}
 	}
+
+	ch := make(chan *rawMessage, 1)
+	id := c.codec.nextID.Add(1) - 1
+	c.pendingMu.Lock()
+	c.pending[id] = pendingRequest{ch: ch, uri: paramsURI(rawParams)}
+	c.pendingMu.Unlock()
 	req := &jsonRPCRequest{JSONRPC: "2.0", ID: id, Method: method, Params: rawParams}
 	if err := c.codec.encode(req); err != nil {
 		c.pendingMu.Lock()
@@ -157,13 +196,78 @@ func (c *VsrocqClient) Request(method string, params any) (json.RawMessage, erro
 		return nil, err
 	}
 
-	resp := <-ch
+	var resp *rawMessage
+	select {
+	case resp = <-ch:
+	case <-c.done:
+		select {
+		case resp = <-ch:
+		default:
+			return nil, errConnClosed
+		}
+	}
 	if resp.Error != nil {
 		return nil, fmt.Errorf("LSP error %d: %s", resp.Error.Code, resp.Error.Message)
 	}
 	return resp.Result, nil
 }
 
+// pendingRequest is a request awaiting its response.
+type pendingRequest struct {
+	ch  chan *rawMessage
+	uri string // the request's textDocument, if it has one
+}
+
+// paramsURI returns the textDocument URI in a request's params, if any.
+func paramsURI(params json.RawMessage) string {
+	var p struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+	}
+	if json.Unmarshal(params, &p) != nil {
+		return ""
+	}
+	return p.TextDocument.URI
+}
+
+// requestCancelled is the LSP error code for a request the client cancelled.
+const requestCancelled = -32800
+
+// cancelPending fails every request about the document uri awaiting a
+// response, as if vsrocq had answered RequestCancelled, and sends
+// $/cancelRequest for each so that vsrocq can abandon the work. It returns
+// the number of requests cancelled.
+func (c *VsrocqClient) cancelPending(uri string) int {
+	c.pendingMu.Lock()
+	pending := make(map[int64]chan *rawMessage)
+	for id, req := range c.pending {
+		if req.uri == uri {
+			pending[id] = req.ch
+			delete(c.pending, id)
+		}
+	}
+	c.pendingMu.Unlock()
+
+	for id, ch := range pending {
+		ch <- &rawMessage{Error: &jsonRPCError{Code: requestCancelled, Message: "interrupted"}}
+		if err := c.Notify("$/cancelRequest", map[string]any{"id": id}); err != nil {
+			log.Printf("send $/cancelRequest: %v", err)
+		}
+	}
+	return len(pending)
+}
+
+// interrupt sends SIGINT to vsrocqtop, which Rocq turns into an
+// "interrupted" error for the sentence it is executing. It does nothing for
+// a client without a subprocess.
+func (c *VsrocqClient) interrupt() error {
+	if c.signal == nil {
+		return nil
+	}
+	return c.signal()
+}
+
 // Notify sends an LSP notification.
 func (c *VsrocqClient) Notify(method string, params any) error {
 	return c.codec.sendNotification(method, params)
@@ -177,13 +281,25 @@ func (c *VsrocqClient) onNotification(method string, handler func(json.RawMessag
 }
 
 // initialize performs the LSP initialize/initialized handshake.
-func (c *VsrocqClient) initialize(rootURI string) error {
+// The first root is also sent as the rootUri.
+func (c *VsrocqClient) initialize(roots []string) error {
 	params := map[string]any{
-		"processId": os.Getpid(),
-		"rootUri":   rootURI,
+		"processId":        os.Getpid(),
+		"rootUri":          FileURI(roots[0]),
+		"workspaceFolders": workspaceFolders(roots),
 		"capabilities": map[string]any{
+			"workspace": map[string]any{"workspaceFolders": true},
 			"textDocument": map[string]any{
 				"publishDiagnostics": map[string]any{},
+				"definition":         map[string]any{"linkSupport": true},
+				"completion": map[string]any{
+					"completionItem": map[string]any{
+						"documentationFormat": []string{"plaintext", "markdown"},
+					},
+				},
+				"hover": map[string]any{
+					"contentFormat": []string{"plaintext", "markdown"},
+				},
 			},
 		},
 	}
@@ -222,5 +338,19 @@ func (c *VsrocqClient) shutdown() error {
 	if err := c.Notify("exit", nil); err != nil {
 		return fmt.Errorf("exit: %w", err)
 	}
-	return c.cmd.Wait()
+	if c.cmd == nil {
+		return nil
+	}
+	<-c.exited
+	return c.waitErr
+}
+
+// kill stops vsrocqtop at once and waits for it to exit. It does nothing
+// for a client without a subprocess.
+func (c *VsrocqClient) kill() {
+	if c.cmd == nil {
+		return
+	}
+	c.cmd.Process.Kill()
+	<-c.exited
 }
@@ -0,0 +1,99 @@
+package rocq
+
+// workspace.go — workspace roots: the folders vsrocq treats as the project,
+// and against which relative file arguments are resolved.
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// SetRoots replaces the workspace roots, given as absolute directories in
+// priority order. If vsrocq is already running, it is sent the added and
+// removed workspace folders.
+func (sm *StateManager) SetRoots(roots []string) error {
+	sm.clientMu.Lock()
+	defer sm.clientMu.Unlock()
+
+	sm.Mu.Lock()
+	sm.roots = slices.Clone(roots)
+	client := sm.Client
+	old := sm.clientRoots
+	if client != nil {
+		sm.clientRoots = workspaceRoots(roots)
+	}
+	current := sm.clientRoots
+	sm.Mu.Unlock()
+	if client == nil {
+		return nil
+	}
+
+	var added, removed []string
+	for _, r := range current {
+		if !slices.Contains(old, r) {
+			added = append(added, r)
+		}
+	}
+	for _, r := range old {
+		if !slices.Contains(current, r) {
+			removed = append(removed, r)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+	params := map[string]any{
+		"event": map[string]any{
+			"added":   workspaceFolders(added),
+			"removed": workspaceFolders(removed),
+		},
+	}
+	return client.Notify("workspace/didChangeWorkspaceFolders", params)
+}
+
+// Roots returns the workspace roots set by SetRoots.
+func (sm *StateManager) Roots() []string {
+	sm.Mu.Lock()
+	defer sm.Mu.Unlock()
+	return slices.Clone(sm.roots)
+}
+
+// workspaceRoots returns the folders to present to vsrocq: the given roots,
+// or the working directory if there are none.
+func workspaceRoots(roots []string) []string {
+	if len(roots) > 0 {
+		return slices.Clone(roots)
+	}
+	cwd, _ := os.Getwd()
+	return []string{cwd}
+}
+
+// workspaceFolders converts directories to LSP WorkspaceFolder values.
+func workspaceFolders(dirs []string) []map[string]any {
+	folders := []map[string]any{}
+	for _, dir := range dirs {
+		folders = append(folders, map[string]any{"uri": FileURI(dir), "name": filepath.Base(dir)})
+	}
+	return folders
+}
+
+// ResolvePath makes a relative file argument absolute against the workspace
+// roots: the first root containing the file, or else the first root. Absolute
+// paths, and all paths when there are no roots, are returned unchanged.
+func (sm *StateManager) ResolvePath(file string) string {
+	if file == "" || filepath.IsAbs(file) {
+		return file
+	}
+	roots := sm.Roots()
+	for _, root := range roots {
+		path := filepath.Join(root, file)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	if len(roots) > 0 {
+		return filepath.Join(roots[0], file)
+	}
+	return file
+}
@@ -0,0 +1,73 @@
+package rocq
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolvePath(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	writeTestFile(t, dir2, "b.v", "")
+
+	sm := NewStateManager(nil)
+	if got := sm.ResolvePath("a.v"); got != "a.v" {
+		t.Errorf("without roots: %q", got)
+	}
+	sm.SetRoots([]string{dir1, dir2})
+	tests := []struct{ file, want string }{
+		{"a.v", filepath.Join(dir1, "a.v")}, // nowhere: first root
+		{"b.v", filepath.Join(dir2, "b.v")}, // under the second root only
+		{"/x/c.v", "/x/c.v"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := sm.ResolvePath(tt.file); got != tt.want {
+			t.Errorf("ResolvePath(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestWorkspaceFoldersSentToProver(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	rec, err := NewRecorder(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm := newEchoStateManager()
+	sm.SetRecorder(rec)
+	sm.SetRoots([]string{dir1})
+	if err := sm.OpenDoc(writeTestFile(t, dir1, "a.v", "")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.SetRoots([]string{dir2}); err != nil {
+		t.Fatal(err)
+	}
+	sm.Shutdown()
+	rec.Close()
+
+	entries, err := ReadTranscript(rec.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := make(map[string]string)
+	for _, e := range entries {
+		var m struct {
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if e.Kind == RecordLSPSend && json.Unmarshal(e.Message, &m) == nil && m.Method != "" {
+			sent[m.Method] = string(m.Params)
+		}
+	}
+	if init := sent["initialize"]; !strings.Contains(init, `"rootUri":"`+FileURI(dir1)+`"`) ||
+		!strings.Contains(init, `"workspaceFolders":[{"name":"`+filepath.Base(dir1)) {
+		t.Errorf("initialize params = %s", init)
+	}
+	change := sent["workspace/didChangeWorkspaceFolders"]
+	added, removed, _ := strings.Cut(change, `"removed"`)
+	if !strings.Contains(added, FileURI(dir2)) || !strings.Contains(removed, FileURI(dir1)) {
+		t.Errorf("didChangeWorkspaceFolders params = %s", change)
+	}
+}
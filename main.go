@@ -4,26 +4,167 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/sanjit/rocq-mcp/internal/rocq"
 )
 
-func main() {
-	// All args after the binary name are passed through to vsrocqtop.
-	vsrocqArgs := os.Args[1:]
+// serverFlags are rocq-mcp's own flags, given before any vsrocqtop flags.
+type serverFlags struct {
+	RecordDir string // --record DIR: write a session transcript to DIR
+	Lifecycle rocq.Lifecycle
+	Limits    rocq.Limits // --max-memory SIZE, --cpu-limit DURATION (proof commands only)
+
+	Allow         []string // --allow DIR (repeatable): file arguments must lie under these
+	SandboxProver bool     // --sandbox-prover: confine vsrocqtop's writes to the allowed roots
+}
+
+// parseServerFlags strips rocq-mcp's own leading flags from args and returns
+// them with the remaining args, which are for vsrocqtop. Flags take their
+// value as the next argument or after '='.
+func parseServerFlags(args []string) (serverFlags, []string, error) {
+	var f serverFlags
+flags:
+	for len(args) > 0 {
+		name, value, hasValue := strings.Cut(args[0], "=")
+		switch name {
+		case "--auto-open":
+			f.Lifecycle.AutoOpen = true
+			args = args[1:]
+			continue
+		case "--sandbox-prover":
+			f.SandboxProver = true
+			args = args[1:]
+			continue
+		case "--record", "--max-docs", "--allow", "--max-memory", "--cpu-limit":
+		default:
+			break flags
+		}
+		if !hasValue {
+			if len(args) < 2 {
+				return f, nil, fmt.Errorf("%s requires a value", name)
+			}
+			value, args = args[1], args[1:]
+		}
+		args = args[1:]
+		switch name {
+		case "--record":
+			f.RecordDir = value
+		case "--max-docs":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return f, nil, fmt.Errorf("--max-docs: invalid count %q", value)
+			}
+			f.Lifecycle.MaxDocs = n
+		case "--allow":
+			f.Allow = append(f.Allow, value)
+		case "--max-memory":
+			n, err := rocq.ParseSize(value)
+			if err != nil {
+				return f, nil, fmt.Errorf("--max-memory: %w", err)
+			}
+			f.Limits.Memory = n
+		case "--cpu-limit":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return f, nil, fmt.Errorf("--cpu-limit: invalid duration %q", value)
+			}
+			f.Limits.CPU = d
+		}
+	}
+	// Without --auto-open, closing a document would break the next call on it.
+	if f.Lifecycle.MaxDocs > 0 && !f.Lifecycle.AutoOpen {
+		return f, nil, fmt.Errorf("--max-docs requires --auto-open")
+	}
+	return f, args, nil
+}
 
+// newStateManager returns a StateManager for vsrocqArgs configured by flags.
+func newStateManager(flags serverFlags, vsrocqArgs []string) (*rocq.StateManager, error) {
 	sm := rocq.NewStateManager(vsrocqArgs)
+	sm.SetLifecycle(flags.Lifecycle)
+	if err := sm.SetLimits(flags.Limits); err != nil {
+		return nil, fmt.Errorf("limits: %w", err)
+	}
+	sm.SetAllowedRoots(flags.Allow)
+	if flags.SandboxProver {
+		writable := flags.Allow
+		if len(writable) == 0 {
+			writable = []string{"."}
+		}
+		if err := sm.SandboxProver(writable); err != nil {
+			return nil, fmt.Errorf("sandbox: %w", err)
+		}
+	}
+	return sm, nil
+}
 
+// newServer returns the MCP server for sm, with its tools, resources and
+// prompts. If rec is non-nil, tool calls and results are recorded to it.
+func newServer(sm *rocq.StateManager, rec *rocq.Recorder) *mcp.Server {
+	initialized, rootsChanged := rootsHandlers(sm)
 	server := mcp.NewServer(&mcp.Implementation{
 		Name:    "rocq-mcp",
 		Version: "0.1.0",
-	}, nil)
+	}, &mcp.ServerOptions{
+		SubscribeHandler:        subscribeResource,
+		UnsubscribeHandler:      unsubscribeResource,
+		InitializedHandler:      initialized,
+		RootsListChangedHandler: rootsChanged,
+	})
+	server.AddReceivingMiddleware(resolveFileArgs(sm))
+
+	if rec != nil {
+		server.AddReceivingMiddleware(recordTools(rec))
+	}
 
 	registerTools(server, sm)
+	registerResources(server, sm)
+	registerPrompts(server, sm)
+	return server
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(runCheck(os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == rocq.SandboxExecCommand {
+		log.Fatal(rocq.RunSandboxed(os.Args[2:]))
+	}
+
+	// Args after rocq-mcp's own flags are passed through to vsrocqtop.
+	flags, vsrocqArgs, err := parseServerFlags(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sm, err := newStateManager(flags, vsrocqArgs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var rec *rocq.Recorder
+	if flags.RecordDir != "" {
+		if rec, err = rocq.NewRecorder(flags.RecordDir); err != nil {
+			log.Fatalf("record: %v", err)
+		}
+		defer rec.Close()
+		// The flags go first, so that a replay runs under the same ones.
+		rec.Record(rocq.RecordEntry{Kind: rocq.RecordServer, Flags: os.Args[1 : len(os.Args)-len(vsrocqArgs)]})
+		sm.SetRecorder(rec)
+		log.Printf("recording session to %s", rec.Path)
+	}
 
+	server := newServer(sm, rec)
 	if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
 		log.Fatalf("server error: %v", err)
 	}
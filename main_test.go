@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+func TestParseServerFlags(t *testing.T) {
+	tests := []struct {
+		args  []string
+		flags serverFlags
+		rest  []string
+	}{
+		{nil, serverFlags{}, nil},
+		{[]string{"-Q", "theories", "Foo"}, serverFlags{}, []string{"-Q", "theories", "Foo"}},
+		{[]string{"--record", "logs", "-R", ".", "X"}, serverFlags{RecordDir: "logs"}, []string{"-R", ".", "X"}},
+		{[]string{"--record=logs"}, serverFlags{RecordDir: "logs"}, []string{}},
+		{[]string{"--auto-open", "--max-docs", "4", "-Q", "t", "T"},
+			serverFlags{Lifecycle: rocq.Lifecycle{AutoOpen: true, MaxDocs: 4}}, []string{"-Q", "t", "T"}},
+		{[]string{"--max-docs=2", "--auto-open"}, serverFlags{Lifecycle: rocq.Lifecycle{AutoOpen: true, MaxDocs: 2}}, []string{}},
+		{[]string{"--allow", "src", "--allow=vendor", "--sandbox-prover", "-Q", "src", "S"},
+			serverFlags{Allow: []string{"src", "vendor"}, SandboxProver: true}, []string{"-Q", "src", "S"}},
+		{[]string{"--max-memory", "8G", "--cpu-limit=90s"},
+			serverFlags{Limits: rocq.Limits{Memory: 8 << 30, CPU: 90 * time.Second}}, []string{}},
+	}
+	for _, tt := range tests {
+		flags, rest, err := parseServerFlags(tt.args)
+		if err != nil || !reflect.DeepEqual(flags, tt.flags) || !slices.Equal(rest, tt.rest) {
+			t.Errorf("parseServerFlags(%q) = %+v, %q, %v; want %+v, %q", tt.args, flags, rest, err, tt.flags, tt.rest)
+		}
+	}
+
+	for _, args := range [][]string{{"--record"}, {"--max-docs", "x"}, {"--max-docs=-1"}, {"--max-docs", "2", "-Q", "t", "T"}, {"--allow"}, {"--max-memory", "lots"}, {"--cpu-limit=soon"}} {
+		if _, _, err := parseServerFlags(args); err == nil {
+			t.Errorf("parseServerFlags(%q): expected error", args)
+		}
+	}
+}
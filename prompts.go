@@ -0,0 +1,89 @@
+package main
+
+// prompts.go — MCP prompt registration for common proof workflows.
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+// userPrompt wraps prompt text as a single user message.
+func userPrompt(description, text string) *mcp.GetPromptResult {
+	return &mcp.GetPromptResult{
+		Description: description,
+		Messages: []*mcp.PromptMessage{
+			{Role: "user", Content: &mcp.TextContent{Text: text}},
+		},
+	}
+}
+
+// intArg parses a required integer prompt argument.
+func intArg(args map[string]string, name string) (int, error) {
+	n, err := strconv.Atoi(args[name])
+	if err != nil {
+		return 0, fmt.Errorf("argument %s: %w", name, err)
+	}
+	return n, nil
+}
+
+// registerPrompts registers workflow prompts filled in with live proof state.
+func registerPrompts(server *mcp.Server, sm *rocq.StateManager) {
+	fileArgument := &mcp.PromptArgument{Name: "file", Description: "path to the .v file", Required: true}
+
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "fix_first_error",
+		Description: "Check a file and set up fixing its first error, with the source and goals around it.",
+		Arguments:   []*mcp.PromptArgument{fileArgument},
+	}, func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		text, err := rocq.FixFirstErrorPrompt(sm, req.Params.Arguments["file"])
+		if err != nil {
+			return nil, err
+		}
+		return userPrompt("Fix the first error", text), nil
+	})
+
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "prove_lemma",
+		Description: "Set up proving a lemma, with its current tactics and remaining goals.",
+		Arguments: []*mcp.PromptArgument{
+			fileArgument,
+			{Name: "name", Description: "name of the lemma to prove", Required: true},
+		},
+	}, func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		args := req.Params.Arguments
+		text, err := rocq.ProveLemmaPrompt(sm, args["file"], args["name"])
+		if err != nil {
+			return nil, err
+		}
+		return userPrompt("Prove "+args["name"], text), nil
+	})
+
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "explain_goal",
+		Description: "Explain the proof state at a position in plain terms.",
+		Arguments: []*mcp.PromptArgument{
+			fileArgument,
+			{Name: "line", Description: "0-indexed line number", Required: true},
+			{Name: "col", Description: "0-indexed column number", Required: true},
+		},
+	}, func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		args := req.Params.Arguments
+		line, err := intArg(args, "line")
+		if err != nil {
+			return nil, err
+		}
+		col, err := intArg(args, "col")
+		if err != nil {
+			return nil, err
+		}
+		text, err := rocq.ExplainGoalPrompt(sm, args["file"], line, col)
+		if err != nil {
+			return nil, err
+		}
+		return userPrompt("Explain the goal", text), nil
+	})
+}
@@ -0,0 +1,32 @@
+package main
+
+// record.go — --record: transcript of tool calls and results for later replay.
+
+import (
+	"context"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+// recordTools returns middleware that records each tools/call request and its result.
+func recordTools(rec *rocq.Recorder) mcp.Middleware {
+	return func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			call, ok := req.(*mcp.CallToolRequest)
+			if !ok {
+				return next(ctx, method, req)
+			}
+			rec.Record(rocq.RecordEntry{Kind: rocq.RecordToolCall, Tool: call.Params.Name, Args: call.Params.Arguments})
+			res, err := next(ctx, method, req)
+			entry := rocq.RecordEntry{Kind: rocq.RecordToolResult, Tool: call.Params.Name}
+			if err != nil {
+				entry.Result, entry.IsError = err.Error(), true
+			} else if r, ok := res.(*mcp.CallToolResult); ok {
+				entry.Result, entry.IsError = rocq.ResultText(r), r.IsError
+			}
+			rec.Record(entry)
+			return res, err
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+// replay.go — `rocq-mcp replay`: re-run a recorded session and report divergences.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+const replayUsage = "Usage: rocq-mcp replay [--fake] <session.jsonl> [-- vsrocqtop flags...]"
+
+// recordedCall is a tool call from a transcript paired with its recorded result.
+type recordedCall struct {
+	rocq.RecordEntry
+	Want    string
+	WantErr bool
+}
+
+// recordedCalls pairs each tool_call entry with the tool_result that follows it.
+func recordedCalls(entries []rocq.RecordEntry) []recordedCall {
+	var calls []recordedCall
+	var open []int // indexes of calls still awaiting a result
+	for _, e := range entries {
+		switch e.Kind {
+		case rocq.RecordToolCall:
+			open = append(open, len(calls))
+			calls = append(calls, recordedCall{RecordEntry: e})
+		case rocq.RecordToolResult:
+			for i, k := range open {
+				if calls[k].Tool == e.Tool {
+					calls[k].Want, calls[k].WantErr = e.Result, e.IsError
+					open = append(open[:i], open[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return calls
+}
+
+// recordedFlags returns the server flags a transcript was recorded with,
+// other than --record, or none for a transcript without them.
+func recordedFlags(entries []rocq.RecordEntry) (serverFlags, error) {
+	for _, e := range entries {
+		if e.Kind != rocq.RecordServer {
+			continue
+		}
+		flags, _, err := parseServerFlags(e.Flags)
+		if err != nil {
+			return flags, fmt.Errorf("recorded flags: %w", err)
+		}
+		flags.RecordDir = ""
+		return flags, nil
+	}
+	return serverFlags{}, nil
+}
+
+// firstDifference describes the first line where want and got differ.
+func firstDifference(want, got string) string {
+	wl, gl := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := range max(len(wl), len(gl)) {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n    recorded: %q\n    replayed: %q", i+1, w, g)
+		}
+	}
+	return "identical text"
+}
+
+// runReplay replays a transcript's tool calls against a fresh server, backed by
+// a real vsrocqtop or, with --fake, by the recorded prover traffic. It returns
+// the process exit code: 0 if every result matched, 1 on divergence, 2 on error.
+func runReplay(args []string, out io.Writer) int {
+	fake := false
+	if len(args) > 0 && args[0] == "--fake" {
+		fake, args = true, args[1:]
+	}
+	if len(args) == 0 || args[0] == "--" {
+		fmt.Fprintln(os.Stderr, replayUsage)
+		return 2
+	}
+	path, vsrocqArgs := args[0], args[1:]
+	if len(vsrocqArgs) > 0 && vsrocqArgs[0] == "--" {
+		vsrocqArgs = vsrocqArgs[1:]
+	}
+
+	entries, err := rocq.ReadTranscript(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		return 2
+	}
+
+	flags, err := recordedFlags(entries)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		return 2
+	}
+	sm, err := newStateManager(flags, vsrocqArgs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		return 2
+	}
+	if fake {
+		sm.UseTranscriptProver(entries)
+	}
+	defer sm.Shutdown()
+
+	server := newServer(sm, nil)
+
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(ctx, serverTransport, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		return 2
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "rocq-mcp-replay"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay: %v\n", err)
+		return 2
+	}
+	defer session.Close()
+
+	calls := recordedCalls(entries)
+	diverged := 0
+	for i, c := range calls {
+		res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: c.Tool, Arguments: c.Args})
+		var got string
+		var gotErr bool
+		if err != nil {
+			got, gotErr = err.Error(), true
+		} else {
+			got, gotErr = rocq.ResultText(res), res.IsError
+		}
+		if got == c.Want && gotErr == c.WantErr {
+			fmt.Fprintf(out, "[%d] %s: ok\n", i+1, c.Tool)
+			continue
+		}
+		diverged++
+		fmt.Fprintf(out, "[%d] %s %s: DIVERGED\n", i+1, c.Tool, c.Args)
+		if gotErr != c.WantErr {
+			fmt.Fprintf(out, "  error: recorded %v, replayed %v\n", c.WantErr, gotErr)
+		}
+		if got != c.Want {
+			fmt.Fprintf(out, "  %s\n", firstDifference(c.Want, got))
+		}
+	}
+
+	fmt.Fprintf(out, "\n%d calls replayed, %d diverged\n", len(calls), diverged)
+	if diverged > 0 {
+		return 1
+	}
+	return 0
+}
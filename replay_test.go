@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+func TestRecordedCalls(t *testing.T) {
+	entries := []rocq.RecordEntry{
+		{Kind: rocq.RecordToolCall, Tool: "rocq_open"},
+		{Kind: rocq.RecordLSPSend},
+		{Kind: rocq.RecordToolCall, Tool: "rocq_check"},
+		{Kind: rocq.RecordToolResult, Tool: "rocq_check", Result: "checked", IsError: true},
+		{Kind: rocq.RecordToolResult, Tool: "rocq_open", Result: "opened"},
+	}
+	calls := recordedCalls(entries)
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls, want 2", len(calls))
+	}
+	if calls[0].Tool != "rocq_open" || calls[0].Want != "opened" || calls[0].WantErr {
+		t.Errorf("calls[0] = %+v", calls[0])
+	}
+	if calls[1].Tool != "rocq_check" || calls[1].Want != "checked" || !calls[1].WantErr {
+		t.Errorf("calls[1] = %+v", calls[1])
+	}
+}
+
+func TestFirstDifference(t *testing.T) {
+	got := firstDifference("a\nb\nc", "a\nx")
+	want := "line 2:\n    recorded: \"b\"\n    replayed: \"x\""
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// A session recorded with --auto-open replays with it: the checkpoint call
+// opens the file instead of failing as not open.
+func TestReplayRecordedFlags(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.v")
+	if err := os.WriteFile(file, []byte("Lemma foo : True.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args, _ := json.Marshal(map[string]string{"file": file, "name": "start"})
+	lsp := func(kind, body string) rocq.RecordEntry {
+		return rocq.RecordEntry{Kind: kind, Message: json.RawMessage(body)}
+	}
+	entries := []rocq.RecordEntry{
+		{Kind: rocq.RecordServer, Flags: []string{"--record", "logs", "--auto-open"}},
+		{Kind: rocq.RecordToolCall, Tool: "rocq_checkpoint", Args: args},
+		lsp(rocq.RecordLSPSend, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`),
+		lsp(rocq.RecordLSPRecv, `{"jsonrpc":"2.0","id":1,"result":{"capabilities":{}}}`),
+		{Kind: rocq.RecordToolResult, Tool: "rocq_checkpoint", Result: `Saved checkpoint "start" at line 1:0 (checkpoints: start)`},
+	}
+	var transcript bytes.Buffer
+	enc := json.NewEncoder(&transcript)
+	for _, e := range entries {
+		enc.Encode(e)
+	}
+	path := filepath.Join(dir, "session.jsonl")
+	if err := os.WriteFile(path, transcript.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if code := runReplay([]string{"--fake", path}, &out); code != 0 {
+		t.Errorf("replay exited %d:\n%s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "1 calls replayed, 0 diverged") {
+		t.Errorf("replay output:\n%s", out.String())
+	}
+}
+
+func TestRecordedFlags(t *testing.T) {
+	flags, err := recordedFlags([]rocq.RecordEntry{{Kind: rocq.RecordServer, Flags: []string{"--record=logs", "--auto-open", "--max-docs", "3"}}})
+	want := serverFlags{Lifecycle: rocq.Lifecycle{AutoOpen: true, MaxDocs: 3}}
+	if err != nil || !reflect.DeepEqual(flags, want) {
+		t.Errorf("recordedFlags = %+v, %v; want %+v", flags, err, want)
+	}
+	if flags, err := recordedFlags(nil); err != nil || !reflect.DeepEqual(flags, serverFlags{}) {
+		t.Errorf("recordedFlags(nil) = %+v, %v", flags, err)
+	}
+}
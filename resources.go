@@ -0,0 +1,176 @@
+package main
+
+// resources.go — MCP resources exposing live per-document proof state, with subscriptions.
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+// Resource URIs are the prefix followed by the document's absolute path,
+// percent-encoded as in its file:// URI, e.g. rocq://goals/home/me/my%20proj/foo.v.
+const (
+	goalsPrefix       = "rocq://goals"
+	diagnosticsPrefix = "rocq://diagnostics"
+)
+
+// subscribeResource accepts subscriptions to rocq:// resources; the SDK tracks subscribers.
+func subscribeResource(ctx context.Context, req *mcp.SubscribeRequest) error {
+	return checkResourceURI(req.Params.URI)
+}
+
+func unsubscribeResource(ctx context.Context, req *mcp.UnsubscribeRequest) error {
+	return checkResourceURI(req.Params.URI)
+}
+
+// resourceURI returns the URI of path's resource under prefix.
+func resourceURI(prefix, path string) string {
+	return prefix + strings.TrimPrefix(rocq.FileURI(path), "file://")
+}
+
+// resourcePath returns the path named by a resource URI under prefix.
+func resourcePath(prefix, uri string) string {
+	return rocq.URIPath("file://" + strings.TrimPrefix(uri, prefix))
+}
+
+func checkResourceURI(uri string) error {
+	if !strings.HasPrefix(uri, goalsPrefix+"/") && !strings.HasPrefix(uri, diagnosticsPrefix+"/") {
+		return fmt.Errorf("unknown resource: %s", uri)
+	}
+	return nil
+}
+
+// registerResources exposes each open document's goals and diagnostics as
+// resources, and notifies subscribers when vsrocq reports changes.
+func registerResources(server *mcp.Server, sm *rocq.StateManager) {
+	updates := newUpdateQueue(func(uri string) { notifyUpdated(server, uri) })
+	readGoals := func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		uri := req.Params.URI
+		pv, _, err := sm.ProofState(resourcePath(goalsPrefix, uri))
+		if err != nil {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		text := "No proof state yet."
+		if pv != nil {
+			text = rocq.FormatProofState(pv, nil)
+		}
+		return textResource(uri, text), nil
+	}
+
+	readDiagnostics := func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		uri := req.Params.URI
+		_, diags, err := sm.ProofState(resourcePath(diagnosticsPrefix, uri))
+		if err != nil {
+			return nil, mcp.ResourceNotFoundError(uri)
+		}
+		var sb strings.Builder
+		rocq.FormatDiagnostics(&sb, diags)
+		text := strings.TrimPrefix(sb.String(), "\n")
+		if text == "" {
+			text = "No diagnostics."
+		}
+		return textResource(uri, text), nil
+	}
+
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "goals",
+		URITemplate: goalsPrefix + "/{+path}",
+		Description: "Current proof goals of an open .v file (absolute path).",
+		MIMEType:    "text/plain",
+	}, readGoals)
+
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		Name:        "diagnostics",
+		URITemplate: diagnosticsPrefix + "/{+path}",
+		Description: "Current diagnostics of an open .v file (absolute path).",
+		MIMEType:    "text/plain",
+	}, readDiagnostics)
+
+	sm.OnDocEvent(func(path, kind string) {
+		switch kind {
+		case rocq.DocOpened:
+			server.AddResource(&mcp.Resource{
+				Name:     "goals " + path,
+				URI:      resourceURI(goalsPrefix, path),
+				MIMEType: "text/plain",
+			}, readGoals)
+			server.AddResource(&mcp.Resource{
+				Name:     "diagnostics " + path,
+				URI:      resourceURI(diagnosticsPrefix, path),
+				MIMEType: "text/plain",
+			}, readDiagnostics)
+		case rocq.DocClosed:
+			server.RemoveResources(resourceURI(goalsPrefix, path), resourceURI(diagnosticsPrefix, path))
+		case rocq.DocGoalsChanged:
+			updates.add(resourceURI(goalsPrefix, path))
+		case rocq.DocDiagnosticsChanged:
+			updates.add(resourceURI(diagnosticsPrefix, path))
+		}
+	})
+}
+
+// updateQueue sends resources/updated notifications from its own goroutine.
+// Goal and diagnostic changes are reported on the vsrocq read loop, which must
+// not wait on a slow MCP client. URIs already queued are not queued again.
+type updateQueue struct {
+	send func(uri string)
+	wake chan struct{}
+
+	mu      sync.Mutex
+	pending []string
+	queued  map[string]bool
+}
+
+func newUpdateQueue(send func(uri string)) *updateQueue {
+	q := &updateQueue{send: send, wake: make(chan struct{}, 1), queued: make(map[string]bool)}
+	go q.run()
+	return q
+}
+
+// add queues a notification for uri without blocking.
+func (q *updateQueue) add(uri string) {
+	q.mu.Lock()
+	if !q.queued[uri] {
+		q.queued[uri] = true
+		q.pending = append(q.pending, uri)
+	}
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *updateQueue) run() {
+	for range q.wake {
+		q.mu.Lock()
+		uris := q.pending
+		q.pending = nil
+		clear(q.queued)
+		q.mu.Unlock()
+		for _, uri := range uris {
+			q.send(uri)
+		}
+	}
+}
+
+func notifyUpdated(server *mcp.Server, uri string) {
+	err := server.ResourceUpdated(context.Background(), &mcp.ResourceUpdatedNotificationParams{URI: uri})
+	if err != nil {
+		log.Printf("resource updated %s: %v", uri, err)
+	}
+}
+
+func textResource(uri, text string) *mcp.ReadResourceResult {
+	return &mcp.ReadResourceResult{
+		Contents: []*mcp.ResourceContents{
+			{URI: uri, MIMEType: "text/plain", Text: text},
+		},
+	}
+}
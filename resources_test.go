@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+func TestUpdateQueue(t *testing.T) {
+	release := make(chan struct{})
+	sent := make(chan string, 8)
+	q := newUpdateQueue(func(uri string) {
+		<-release // a client that is slow to read
+		sent <- uri
+	})
+
+	added := make(chan struct{})
+	go func() {
+		for range 100 {
+			q.add("rocq://goals/a.v")
+			q.add("rocq://diagnostics/a.v")
+		}
+		close(added)
+	}()
+	select {
+	case <-added:
+	case <-time.After(time.Second):
+		t.Fatal("add blocked on a slow client")
+	}
+
+	close(release)
+	var got []string
+	for {
+		select {
+		case uri := <-sent:
+			got = append(got, uri)
+			continue
+		case <-time.After(200 * time.Millisecond):
+		}
+		break
+	}
+	// At most one batch was taken before the rest were queued; the 200
+	// changes after it are coalesced into one notification per URI.
+	seen := map[string]int{}
+	for _, uri := range got {
+		seen[uri]++
+	}
+	if seen["rocq://goals/a.v"] == 0 || seen["rocq://diagnostics/a.v"] == 0 || len(got) > 4 {
+		t.Errorf("sent %q", got)
+	}
+}
+
+func TestResourceURI(t *testing.T) {
+	path := filepath.Join(rocq.CanonicalPath(t.TempDir()), "my proj", "a#b%c?.v")
+	uri := resourceURI(goalsPrefix, path)
+	u, err := url.Parse(uri)
+	if err != nil || u.Fragment != "" || u.RawQuery != "" || strings.Contains(uri, " ") {
+		t.Fatalf("resourceURI(%q) = %q: not a valid URI for the path (%v)", path, uri, err)
+	}
+	if !strings.HasPrefix(uri, goalsPrefix+"/") || !strings.Contains(uri, "my%20proj/a%23b%25c%3F.v") {
+		t.Errorf("resourceURI(%q) = %q", path, uri)
+	}
+	if got := resourcePath(goalsPrefix, uri); got != path {
+		t.Errorf("resourcePath(%q) = %q, want %q", uri, got, path)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+// roots.go — MCP client roots as the workspace, and resolution of relative
+// file arguments against them.
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"path/filepath"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+// rootsHandlers returns handlers that fetch the client's roots after
+// initialization and whenever the client reports that they changed.
+func rootsHandlers(sm *rocq.StateManager) (func(context.Context, *mcp.InitializedRequest), func(context.Context, *mcp.RootsListChangedRequest)) {
+	initialized := func(ctx context.Context, req *mcp.InitializedRequest) {
+		if params := req.Session.InitializeParams(); params != nil && params.Capabilities != nil && params.Capabilities.RootsV2 != nil {
+			// Not inline: the client's reply cannot be read while this handler runs.
+			go syncRoots(req.Session, sm)
+		}
+	}
+	changed := func(ctx context.Context, req *mcp.RootsListChangedRequest) {
+		go syncRoots(req.Session, sm)
+	}
+	return initialized, changed
+}
+
+// syncRoots lists the client's roots and makes their directories the workspace roots.
+func syncRoots(ss *mcp.ServerSession, sm *rocq.StateManager) {
+	res, err := ss.ListRoots(context.Background(), nil)
+	if err != nil {
+		log.Printf("list roots: %v", err)
+		return
+	}
+	var roots []string
+	for _, r := range res.Roots {
+		roots = append(roots, rocq.URIPath(r.URI))
+	}
+	if err := sm.SetRoots(roots); err != nil {
+		log.Printf("set roots: %v", err)
+	}
+}
+
+// resolveFileArgs returns middleware that makes relative "file" arguments of
+// tool calls and prompts absolute against the workspace roots.
+func resolveFileArgs(sm *rocq.StateManager) mcp.Middleware {
+	return func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			switch r := req.(type) {
+			case *mcp.CallToolRequest:
+				r.Params.Arguments = resolveFileArg(sm, r.Params.Arguments)
+			case *mcp.GetPromptRequest:
+				if file, ok := r.Params.Arguments["file"]; ok && !filepath.IsAbs(file) {
+					r.Params.Arguments["file"] = sm.ResolvePath(file)
+				}
+			}
+			return next(ctx, method, req)
+		}
+	}
+}
+
+// resolveFileArg rewrites a relative "file" field of raw tool arguments.
+func resolveFileArg(sm *rocq.StateManager, raw json.RawMessage) json.RawMessage {
+	var args map[string]any
+	if json.Unmarshal(raw, &args) != nil {
+		return raw
+	}
+	file, ok := args["file"].(string)
+	if !ok || filepath.IsAbs(file) {
+		return raw
+	}
+	args["file"] = sm.ResolvePath(file)
+	out, err := json.Marshal(args)
+	if err != nil {
+		return raw
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sanjit/rocq-mcp/internal/rocq"
+)
+
+func TestClientRoots(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir2, "b.v"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := rocq.NewStateManager(nil)
+	initialized, rootsChanged := rootsHandlers(sm)
+	server := mcp.NewServer(&mcp.Implementation{Name: "rocq-mcp"}, &mcp.ServerOptions{
+		InitializedHandler:      initialized,
+		RootsListChangedHandler: rootsChanged,
+	})
+	server.AddReceivingMiddleware(resolveFileArgs(sm))
+	mcp.AddTool(server, &mcp.Tool{Name: "echo_file"}, func(ctx context.Context, req *mcp.CallToolRequest, args fileArg) (*mcp.CallToolResult, any, error) {
+		return rocq.TextResult(args.File), nil, nil
+	})
+
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := server.Connect(ctx, serverTransport, nil); err != nil {
+		t.Fatal(err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client"}, nil)
+	client.AddRoots(&mcp.Root{URI: rocq.FileURI(dir1)})
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	waitRoots := func(want ...string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !slices.Equal(sm.Roots(), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("roots = %q, want %q", sm.Roots(), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	echo := func(file string) string {
+		t.Helper()
+		res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "echo_file", Arguments: map[string]any{"file": file}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resultText(res)
+	}
+
+	waitRoots(dir1)
+	if got, want := echo("a.v"), filepath.Join(dir1, "a.v"); got != want {
+		t.Errorf("echo(a.v) = %q, want %q", got, want)
+	}
+
+	// A file found only under the second root resolves there.
+	client.AddRoots(&mcp.Root{URI: rocq.FileURI(dir2)})
+	waitRoots(dir1, dir2)
+	if got, want := echo("b.v"), filepath.Join(dir2, "b.v"); got != want {
+		t.Errorf("echo(b.v) = %q, want %q", got, want)
+	}
+	if got := echo("/abs/c.v"); got != "/abs/c.v" {
+		t.Errorf("absolute path rewritten to %q", got)
+	}
+}
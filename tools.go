@@ -4,6 +4,9 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/sanjit/rocq-mcp/internal/rocq"
@@ -15,20 +18,89 @@ type fileArg struct {
 	File string `json:"file" jsonschema:"path to the .v file"`
 }
 
+type checkAllArg struct {
+	File  string `json:"file" jsonschema:"path to the .v file"`
+	Async bool   `json:"async,omitempty" jsonschema:"run in the background and return a job ID at once"`
+}
+
+type jobArg struct {
+	ID string `json:"id" jsonschema:"job ID returned by an async tool call"`
+}
+
+type jobResultArg struct {
+	ID          string `json:"id" jsonschema:"job ID returned by an async tool call"`
+	WaitSeconds int    `json:"wait_seconds,omitempty" jsonschema:"how long to wait for the job to finish (default 30)"`
+}
+
 type checkArg struct {
 	File string `json:"file" jsonschema:"path to the .v file"`
 	Line int    `json:"line" jsonschema:"0-indexed line number"`
 	Col  int    `json:"col" jsonschema:"0-indexed column number"`
 }
 
+type checkProofArg struct {
+	File     string `json:"file" jsonschema:"path to the .v file"`
+	Name     string `json:"name" jsonschema:"name of the lemma/theorem/definition whose proof to check"`
+	Position string `json:"position,omitempty" jsonschema:"where to check: 'start' (after the statement), 'end' (after the last tactic, default) or 'step'"`
+	Step     int    `json:"step,omitempty" jsonschema:"with position 'step': check after this tactic (1-indexed)"`
+}
+
+type completeArg struct {
+	File   string `json:"file" jsonschema:"path to the .v file"`
+	Line   int    `json:"line" jsonschema:"0-indexed line number"`
+	Col    int    `json:"col" jsonschema:"0-indexed column number"`
+	Prefix string `json:"prefix,omitempty" jsonschema:"identifier prefix to complete, e.g. 'Nat.add_c' (default: the text before the position)"`
+}
+
+type checkpointArg struct {
+	File string `json:"file" jsonschema:"path to the .v file"`
+	Name string `json:"name" jsonschema:"checkpoint name"`
+}
+
 type queryArg struct {
 	File    string `json:"file" jsonschema:"path to the .v file"`
 	Pattern string `json:"pattern" jsonschema:"the identifier or expression to query"`
+	Line    *int   `json:"line,omitempty" jsonschema:"0-indexed line whose proof context to query in (default: current execution point)"`
+	Col     *int   `json:"col,omitempty" jsonschema:"0-indexed column (default: 0 if line is given)"`
 }
 
 type searchArg struct {
-	File    string `json:"file" jsonschema:"path to the .v file"`
-	Pattern string `json:"pattern" jsonschema:"search pattern (e.g. 'nat -> nat', '_ + _ = _ + _')"`
+	File    string       `json:"file" jsonschema:"path to the .v file"`
+	Pattern string       `json:"pattern" jsonschema:"search pattern (e.g. 'nat -> nat', '_ + _ = _ + _')"`
+	Line    *int         `json:"line,omitempty" jsonschema:"0-indexed line whose context to search in (default: current execution point)"`
+	Col     *int         `json:"col,omitempty" jsonschema:"0-indexed column (default: 0 if line is given)"`
+	Kind    string       `json:"kind,omitempty" jsonschema:"search semantics: 'search' (default), 'pattern' (SearchPattern) or 'rewrite' (SearchRewrite)"`
+	Modules *moduleScope `json:"modules,omitempty" jsonschema:"restrict the search to or away from modules"`
+	Name    string       `json:"name,omitempty" jsonschema:"regexp that result names must match"`
+	Limit   int          `json:"limit,omitempty" jsonschema:"maximum results to return (default 50)"`
+	Offset  int          `json:"offset,omitempty" jsonschema:"number of ranked results to skip, for paging"`
+}
+
+type moduleScope struct {
+	Include []string `json:"include,omitempty" jsonschema:"only search inside these modules"`
+	Exclude []string `json:"exclude,omitempty" jsonschema:"skip these modules"`
+}
+
+// optPosition builds a query position from optional line/col arguments.
+// Returns nil (use the current execution point) when no line is given.
+func optPosition(line, col *int) *rocq.Position {
+	if line == nil {
+		return nil
+	}
+	pos := &rocq.Position{Line: *line}
+	if col != nil {
+		pos.Character = *col
+	}
+	return pos
+}
+
+// openDescription describes rocq_open under the given lifecycle policy.
+func openDescription(l rocq.Lifecycle) string {
+	if l.AutoOpen {
+		return "Open a .v file in the Rocq proof checker. Optional: other tools open files on first use. " +
+			"Calling it on an open file syncs the file if it changed on disk."
+	}
+	return "Open a .v file in the Rocq proof checker. Must be called before any other operations on the file."
 }
 
 // registerTools registers all MCP tools on the server.
@@ -36,10 +108,25 @@ func registerTools(server *mcp.Server, sm *rocq.StateManager) {
 	// Tier 1: Core proof interaction.
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "rocq_open",
-		Description: "Open a .v file in the Rocq proof checker. Must be called before any other operations on the file.",
+		Description: openDescription(sm.Lifecycle()),
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args fileArg) (*mcp.CallToolResult, any, error) {
-		if err := sm.OpenDoc(args.File); err != nil {
+		if !sm.Lifecycle().AutoOpen {
+			if err := sm.OpenDoc(args.File); err != nil {
+				return rocq.ErrResult(err), nil, nil
+			}
+			return rocq.TextResult("Opened " + args.File), nil, nil
+		}
+		switch action, err := sm.EnsureOpen(args.File); {
+		case err != nil:
 			return rocq.ErrResult(err), nil, nil
+		case action == rocq.OpenSynced:
+			return rocq.TextResult("Already open; synced changes from disk: " + args.File), nil, nil
+		case action == rocq.OpenUnchanged:
+			return rocq.TextResult("Already open and unchanged: " + args.File), nil, nil
+		}
+		if lost := sm.LostCheckpoints(args.File); len(lost) > 0 {
+			return rocq.TextResult(fmt.Sprintf("Opened %s; it was closed as idle earlier (--max-docs) and its checkpoints were lost: %s",
+				args.File, strings.Join(lost, ", "))), nil, nil
 		}
 		return rocq.TextResult("Opened " + args.File), nil, nil
 	})
@@ -73,11 +160,21 @@ func registerTools(server *mcp.Server, sm *rocq.StateManager) {
 
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "rocq_check_all",
-		Description: "Check the entire file. Returns proof goals (if any remain) and all diagnostics.",
-	}, func(ctx context.Context, req *mcp.CallToolRequest, args fileArg) (*mcp.CallToolResult, any, error) {
+		Description: "Check the entire file. Returns proof goals (if any remain) and all diagnostics. With async, returns a job ID at once for rocq_job_status, rocq_job_result and rocq_job_cancel.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args checkAllArg) (*mcp.CallToolResult, any, error) {
+		if args.Async {
+			return rocq.DoCheckAllAsync(sm, args.File)
+		}
 		return rocq.DoCheckAll(sm, args.File)
 	})
 
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "rocq_check_proof",
+		Description: "Check a proof by name instead of coordinates: at its start, its end, or after its Nth tactic. Returns the proof's tactic list and the goals at that point.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args checkProofArg) (*mcp.CallToolResult, any, error) {
+		return rocq.DoCheckProof(sm, args.File, args.Name, args.Position, args.Step)
+	})
+
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "rocq_step_forward",
 		Description: "Step forward one sentence in the proof. Returns updated proof goals.",
@@ -92,40 +189,85 @@ func registerTools(server *mcp.Server, sm *rocq.StateManager) {
 		return rocq.DoStep(sm, args.File, "prover/stepBackward")
 	})
 
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "rocq_checkpoint",
+		Description: "Save the file's current content and execution point under a name, to restore after a risky edit.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args checkpointArg) (*mcp.CallToolResult, any, error) {
+		return rocq.DoCheckpoint(sm, args.File, args.Name)
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "rocq_restore",
+		Description: "Restore a named checkpoint: writes the saved content back to disk, re-syncs, and re-checks to the saved position. Returns the restored goals.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args checkpointArg) (*mcp.CallToolResult, any, error) {
+		return rocq.DoRestore(sm, args.File, args.Name)
+	})
+
 	// Tier 2: Query tools.
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "rocq_about",
 		Description: "Show information about an identifier (type, module, etc). Like Rocq's 'About' command.",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args queryArg) (*mcp.CallToolResult, any, error) {
-		return rocq.DoQuery(sm, args.File, "prover/about", args.Pattern)
+		return rocq.DoQuery(sm, args.File, "prover/about", args.Pattern, optPosition(args.Line, args.Col))
 	})
 
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "rocq_check_type",
 		Description: "Check the type of an expression. Like Rocq's 'Check' command.",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args queryArg) (*mcp.CallToolResult, any, error) {
-		return rocq.DoQuery(sm, args.File, "prover/check", args.Pattern)
+		return rocq.DoQuery(sm, args.File, "prover/check", args.Pattern, optPosition(args.Line, args.Col))
 	})
 
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "rocq_locate",
 		Description: "Locate the defining module of an identifier. Like Rocq's 'Locate' command.",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args queryArg) (*mcp.CallToolResult, any, error) {
-		return rocq.DoQuery(sm, args.File, "prover/locate", args.Pattern)
+		return rocq.DoQuery(sm, args.File, "prover/locate", args.Pattern, optPosition(args.Line, args.Col))
 	})
 
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "rocq_print",
 		Description: "Print the full definition of an identifier. Like Rocq's 'Print' command.",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args queryArg) (*mcp.CallToolResult, any, error) {
-		return rocq.DoQuery(sm, args.File, "prover/print", args.Pattern)
+		return rocq.DoQuery(sm, args.File, "prover/print", args.Pattern, optPosition(args.Line, args.Col))
 	})
 
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "rocq_search",
-		Description: "Search for lemmas matching a pattern. Like Rocq's 'Search' command. Results may be large; use specific patterns.",
+		Description: "Search for lemmas matching a pattern. Like Rocq's 'Search', 'SearchPattern' or 'SearchRewrite' commands. Results are ranked (this file first, then shorter statements) and paged; use offset to continue.",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args searchArg) (*mcp.CallToolResult, any, error) {
-		return rocq.DoSearch(sm, args.File, args.Pattern)
+		opts := rocq.SearchOptions{
+			Kind:   args.Kind,
+			Name:   args.Name,
+			Limit:  args.Limit,
+			Offset: args.Offset,
+		}
+		if args.Modules != nil {
+			opts.Inside = args.Modules.Include
+			opts.Outside = args.Modules.Exclude
+		}
+		return rocq.DoSearch(sm, args.File, args.Pattern, optPosition(args.Line, args.Col), opts)
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "rocq_definition",
+		Description: "Go to the definition of the identifier at a position. Returns the defining file, line and a source excerpt, looking in library sources on the load path when possible.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args checkArg) (*mcp.CallToolResult, any, error) {
+		return rocq.DoDefinition(sm, args.File, args.Line, args.Col)
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "rocq_hover",
+		Description: "Show the type and doc comment of the identifier at a position.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args checkArg) (*mcp.CallToolResult, any, error) {
+		return rocq.DoHover(sm, args.File, args.Line, args.Col)
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "rocq_complete",
+		Description: "Complete an identifier prefix at a position. Returns candidate names with their kinds and types, closest in scope first. Use it to check lemma names before using them.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args completeArg) (*mcp.CallToolResult, any, error) {
+		return rocq.DoComplete(sm, args.File, args.Line, args.Col, args.Prefix)
 	})
 
 	// Tier 3: Diagnostics & state.
@@ -136,10 +278,56 @@ func registerTools(server *mcp.Server, sm *rocq.StateManager) {
 		return rocq.DoReset(sm, args.File)
 	})
 
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "rocq_interrupt",
+		Description: "Interrupt a runaway computation (a long Compute, a looping tactic) in a file. The tool call waiting on it returns an 'Interrupted at line N' error, and the file stays usable without rocq_reset.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args fileArg) (*mcp.CallToolResult, any, error) {
+		return rocq.DoInterrupt(sm, args.File)
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "rocq_job_status",
+		Description: "Report a background job's state, elapsed time, progress through its file, and the diagnostics so far.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args jobArg) (*mcp.CallToolResult, any, error) {
+		return rocq.DoJobStatus(sm, args.ID)
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "rocq_job_result",
+		Description: "Wait for a background job to finish and return its result. If it is still running after wait_seconds, returns its status instead.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args jobResultArg) (*mcp.CallToolResult, any, error) {
+		wait := 30 * time.Second
+		if args.WaitSeconds > 0 {
+			wait = time.Duration(args.WaitSeconds) * time.Second
+		}
+		return rocq.DoJobResult(sm, args.ID, wait)
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "rocq_job_cancel",
+		Description: "Cancel a background job. A running check is interrupted as by rocq_interrupt, and its result shows where it stopped.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args jobArg) (*mcp.CallToolResult, any, error) {
+		return rocq.DoJobCancel(sm, args.ID)
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "rocq_document_state",
+		Description: "Show vsrocq's internal document state: each sentence with its execution status, error spans, and unprocessed regions. Useful for debugging why a line has not run.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args fileArg) (*mcp.CallToolResult, any, error) {
+		return rocq.DoDocumentState(sm, args.File)
+	})
+
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "rocq_document_proofs",
 		Description: "List all proof blocks in a file with their statements, tactics, and line ranges. Useful for navigating and understanding proof structure.",
 	}, func(ctx context.Context, req *mcp.CallToolRequest, args fileArg) (*mcp.CallToolResult, any, error) {
 		return rocq.DoDocumentProofs(sm, args.File)
 	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "rocq_outline",
+		Description: "Show the structure of a .v file as a tree with line ranges: sections/modules, declarations, Require/Import lines, and proofs with their status. Works without executing the file, and without opening it.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, args fileArg) (*mcp.CallToolResult, any, error) {
+		return rocq.DoOutline(sm, args.File)
+	})
 }